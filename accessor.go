@@ -0,0 +1,156 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// accessor is the path to a field compiled for a data type.
+type accessor struct {
+	// parts contains the path elements of the field's Id.
+	parts []string
+	// fields contains the indices of struct fields for path elements which
+	// step into a struct of a statically known type, and nil for other
+	// path elements.
+	fields [][]int
+	// static is true iff the field's type is known without looking at the
+	// data, i.e. there are no interfaces on the path.
+	static bool
+	// fieldType is the field's type if static is true and the path is valid.
+	fieldType reflect.Type
+	// err describes why the path is not valid for the data type if static is
+	// true.
+	err error
+}
+
+// accessorKey identifies an accessor in the registry.
+type accessorKey struct {
+	dataType reflect.Type
+	id       string
+}
+
+// fieldKey identifies a struct field in the registry.
+type fieldKey struct {
+	structType reflect.Type
+	name       string
+}
+
+var (
+	// accessors caches accessors by accessorKey.
+	accessors sync.Map
+	// structFields caches indices of exported struct fields by fieldKey.
+	structFields sync.Map
+	// cacheAccessors may be disabled to compare performance.
+	cacheAccessors = true
+)
+
+// getAccessor returns the accessor of the field with the given Id of the
+// given data type.
+func getAccessor(dataType reflect.Type, id string) *accessor {
+	if !cacheAccessors {
+		return compileAccessor(dataType, id)
+	}
+	key := accessorKey{dataType, id}
+	if acc, ok := accessors.Load(key); ok {
+		return acc.(*accessor)
+	}
+	acc, _ := accessors.LoadOrStore(key, compileAccessor(dataType, id))
+	return acc.(*accessor)
+}
+
+// compileAccessor compiles the accessor of the field with the given Id of
+// the given data type.
+func compileAccessor(dataType reflect.Type, id string) *accessor {
+	parts := splitField(id)
+	acc := &accessor{parts: parts, fields: make([][]int, len(parts)),
+		static: true}
+	for i, part := range parts {
+		for dataType.Kind() == reflect.Ptr {
+			dataType = dataType.Elem()
+		}
+		switch dataType.Kind() {
+		case reflect.Interface:
+			acc.static = false
+			return acc
+		case reflect.Struct:
+			structField, ok := dataType.FieldByName(part)
+			if !ok {
+				acc.err = fmt.Errorf("%v has no field %q", dataType, part)
+				return acc
+			}
+			if structField.PkgPath != "" {
+				acc.err = fmt.Errorf("field %q of %v is not exported", part,
+					dataType)
+				return acc
+			}
+			acc.fields[i] = structField.Index
+			dataType = structField.Type
+		case reflect.Map:
+			if dataType.Key().Kind() != reflect.String {
+				acc.err = fmt.Errorf("%v has no string keys", dataType)
+				return acc
+			}
+			dataType = dataType.Elem()
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 {
+				acc.err = fmt.Errorf("%q is not a valid index into %v", part,
+					dataType)
+				return acc
+			}
+			if dataType.Kind() == reflect.Array && index >= dataType.Len() {
+				acc.err = fmt.Errorf("index %v is out of range of %v", index,
+					dataType)
+				return acc
+			}
+			dataType = dataType.Elem()
+		default:
+			acc.err = fmt.Errorf("%v has no field %q", dataType, part)
+			return acc
+		}
+	}
+	if dataType.Kind() == reflect.Interface {
+		acc.static = false
+		return acc
+	}
+	acc.fieldType = dataType
+	return acc
+}
+
+// structFieldIndex returns the index of the exported field with the given
+// name of the given struct type.
+func structFieldIndex(structType reflect.Type, name string) ([]int, bool) {
+	key := fieldKey{structType, name}
+	if cacheAccessors {
+		if index, ok := structFields.Load(key); ok {
+			return index.([]int), index.([]int) != nil
+		}
+	}
+	var index []int
+	if structField, ok := structType.FieldByName(name); ok &&
+		structField.PkgPath == "" {
+		index = structField.Index
+	}
+	if cacheAccessors {
+		structFields.Store(key, index)
+	}
+	return index, index != nil
+}
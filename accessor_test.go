@@ -0,0 +1,154 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCompileAccessor(t *testing.T) {
+	dataType := reflect.TypeOf(&TestCheckData{})
+	tests := []struct {
+		Id        string
+		Static    bool
+		FieldType reflect.Type
+		Err       string
+		Fields    [][]int
+	}{
+		{"Name", true, reflect.TypeOf(""), "", [][]int{{0}}},
+		{"Address.City.Zip", true, reflect.TypeOf((*int)(nil)), "",
+			[][]int{{4}, {0}, {1}}},
+		{"Items[2].Name", true, reflect.TypeOf(""), "",
+			[][]int{{7}, nil, {0}}},
+		{"Numbers.Foo", true, reflect.TypeOf(0), "", [][]int{{5}, nil}},
+		{"Extra.Foo.Bar", false, nil, "", [][]int{{6}, nil, nil}},
+		{"Address.Town", true, nil,
+			`form.TestAllocAddress has no field "Town"`, [][]int{{4}, nil}},
+	}
+	for _, test := range tests {
+		acc := compileAccessor(dataType, test.Id)
+		err := ""
+		if acc.err != nil {
+			err = acc.err.Error()
+		}
+		if acc.static != test.Static || acc.fieldType != test.FieldType ||
+			err != test.Err || !reflect.DeepEqual(acc.fields, test.Fields) {
+			t.Errorf("compileAccessor(%q) = %#v", test.Id, acc)
+		}
+	}
+}
+
+func TestAccessorsConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				data := TestAllocData{}
+				form := NewForm(&data, []Field{
+					Field{Id: "Address.Street"},
+					Field{Id: "Extra.Foo.Bar"}})
+				street := fmt.Sprint(i, j)
+				form.Fill(url.Values{"Address.Street": []string{street},
+					"Extra.Foo.Bar": []string{street}})
+				if data.Address == nil || data.Address.Street != street ||
+					data.Extra["Foo"].(map[string]interface{})["Bar"] != street {
+					t.Errorf("Filled data is %#v", data)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// benchmarkForm returns a form representing a typical signup form.
+func benchmarkForm() (*Form, url.Values) {
+	data := struct {
+		Name, Email, Password string
+		Age                   int
+		Address               TestAllocAddress
+		Extra                 map[string]interface{}
+	}{Extra: map[string]interface{}{"Newsletter": false}}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Label: "Name", Validator: Required("Req!")},
+		Field{Id: "Email", Label: "Email", Validator: Regex("@", "Invalid!")},
+		Field{Id: "Password", Label: "Password", Validator: Required("Req!"),
+			Widget: new(PasswordWidget)},
+		Field{Id: "Age", Label: "Age"},
+		Field{Id: "Address.Street", Label: "Street"},
+		Field{Id: "Address.City.Name", Label: "City"},
+		Field{Id: "Extra.Newsletter", Label: "Newsletter"}})
+	values := url.Values{
+		"Name":              []string{"Alice"},
+		"Email":             []string{"alice@example.com"},
+		"Password":          []string{"secret"},
+		"Age":               []string{"42"},
+		"Address.Street":    []string{"Main Street"},
+		"Address.City.Name": []string{"Springfield"},
+		"Extra.Newsletter":  []string{"true"}}
+	return form, values
+}
+
+// benchmarkCached runs the given benchmark with and without cached
+// accessors.
+func benchmarkCached(b *testing.B, run func(b *testing.B)) {
+	defer func() { cacheAccessors = true }()
+	for _, cached := range []bool{false, true} {
+		cacheAccessors = cached
+		b.Run(fmt.Sprintf("cached=%v", cached), func(b *testing.B) {
+			b.ReportAllocs()
+			run(b)
+		})
+	}
+}
+
+func BenchmarkFill(b *testing.B) {
+	benchmarkCached(b, func(b *testing.B) {
+		form, values := benchmarkForm()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			form.Fill(values)
+		}
+	})
+}
+
+func BenchmarkRenderData(b *testing.B) {
+	benchmarkCached(b, func(b *testing.B) {
+		form, values := benchmarkForm()
+		form.Fill(values)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			form.RenderData()
+		}
+	})
+}
+
+func BenchmarkGetNestedField(b *testing.B) {
+	benchmarkCached(b, func(b *testing.B) {
+		form, values := benchmarkForm()
+		form.Fill(values)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			form.getNestedField("Address.City.Name")
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"net/url"
+	"time"
+)
+
+// Parameters used by the spam protection in addition to the form's fields.
+const (
+	// AntiSpamTimeParam contains the signed time the form was rendered at.
+	AntiSpamTimeParam = "antispam.time"
+	// AntiSpamHoneypotParam is the default name of the honeypot field.
+	AntiSpamHoneypotParam = "homepage"
+)
+
+// AntiSpam configures the spam protection of a form, see Form.AntiSpam.
+//
+// It adds a honeypot field, which is invisible to users but gets filled by
+// many bots, and the signed time the form was rendered at to
+// RenderData.Hidden. Fill rejects submissions with a filled honeypot, an
+// invalid time or submitted too fast or too late and adds a global error.
+//
+// The signed time is bound to the form's Action but not to a user or
+// session. It may be submitted any number of times until MaxAge elapsed,
+// so it doesn't prevent replayed submissions.
+type AntiSpam struct {
+	// Key is the secret key used to sign the time. It must not be empty.
+	Key []byte
+	// Honeypot is the name of the honeypot field. It should be attractive
+	// to bots and must not be used by other fields. Defaults to
+	// AntiSpamHoneypotParam if empty.
+	Honeypot string
+	// HoneypotLabel is the label of the honeypot field for users of
+	// browsers without CSS. The field is hidden from screen readers.
+	// Defaults to "Leave this field empty." if empty.
+	HoneypotLabel string
+	// MinAge rejects submissions made faster after rendering the form if
+	// not zero, e.g. 3 seconds.
+	MinAge time.Duration
+	// MaxAge rejects submissions made later after rendering the form.
+	// Defaults to 24 hours if zero.
+	MaxAge time.Duration
+	// Msg is the error for submissions rejected as spam. Defaults to
+	// "Your submission looks like spam. Please try again." if empty.
+	Msg string
+	// ExpiredMsg is the error for submissions rejected due to MaxAge.
+	// Defaults to "The form has expired. Please submit it again." if empty.
+	ExpiredMsg string
+}
+
+func (a *AntiSpam) honeypot() string {
+	if a.Honeypot == "" {
+		return AntiSpamHoneypotParam
+	}
+	return a.Honeypot
+}
+
+func (a *AntiSpam) maxAge() time.Duration {
+	if a.MaxAge == 0 {
+		return 24 * time.Hour
+	}
+	return a.MaxAge
+}
+
+// purpose returns the purpose of signed times of the form with the given
+// action.
+func (a *AntiSpam) purpose(action string) string {
+	return "antispam:" + action
+}
+
+// hidden returns the honeypot field and the signed current time for the
+// form with the given action.
+func (a *AntiSpam) hidden(action string) (template.HTML, error) {
+	if len(a.Key) == 0 {
+		return "", fmt.Errorf("form: AntiSpam has no Key")
+	}
+	label := a.HoneypotLabel
+	if label == "" {
+		label = "Leave this field empty."
+	}
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(now().UnixNano()))
+	return template.HTML(fmt.Sprintf(
+		`<div style="position: absolute; left: -10000px;" aria-hidden="true">`+
+			`<label>%v <input type="text" name="%v" value="" tabindex="-1" `+
+			`autocomplete="off"/></label></div>`+
+			`<input type="hidden" name="%v" value="%v"/>`,
+		template.HTMLEscapeString(label),
+		template.HTMLEscapeString(a.honeypot()), AntiSpamTimeParam,
+		template.HTMLEscapeString(sign(a.Key, a.purpose(action),
+			payload)))), nil
+}
+
+// checkSpam checks the given values according to the form's spam
+// protection and adds a global error if they are rejected.
+//
+// Returns true iff the values are accepted and an error if the form has no
+// key.
+func (f *Form) checkSpam(values url.Values) (bool, error) {
+	if len(f.AntiSpam.Key) == 0 {
+		return false, fmt.Errorf("form: AntiSpam has no Key")
+	}
+	msg := f.AntiSpam.Msg
+	if msg == "" {
+		msg = "Your submission looks like spam. Please try again."
+	}
+	payload, err := verify(f.AntiSpam.Key, f.AntiSpam.purpose(f.Action),
+		values.Get(AntiSpamTimeParam))
+	if err != nil || len(payload) != 8 ||
+		values.Get(f.AntiSpam.honeypot()) != "" {
+		f.AddError("", msg)
+		return false, nil
+	}
+	age := now().Sub(time.Unix(0, int64(binary.BigEndian.Uint64(payload))))
+	switch {
+	case f.AntiSpam.MinAge != 0 && age < f.AntiSpam.MinAge:
+		f.AddError("", msg)
+		return false, nil
+	case age > f.AntiSpam.maxAge():
+		expiredMsg := f.AntiSpam.ExpiredMsg
+		if expiredMsg == "" {
+			expiredMsg = "The form has expired. Please submit it again."
+		}
+		f.AddError("", expiredMsg)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,116 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var antiSpamTimeRegexp = regexp.MustCompile(
+	`name="antispam.time" value="([^"]*)"`)
+
+func TestAntiSpam(t *testing.T) {
+	defer func() { now = time.Now }()
+	rendered := time.Unix(1000000, 0)
+	now = func() time.Time { return rendered }
+	spec := NewFormSpec(Form{
+		Fields: []Field{Field{Id: "Name"}},
+		Action: "/signup",
+		AntiSpam: &AntiSpam{Key: []byte("secret"), MinAge: 3 * time.Second,
+			MaxAge: time.Hour, ExpiredMsg: "Expired!"},
+		Strict: &StrictMode{}})
+	data := struct{ Name string }{}
+	hidden := spec.New(&data).RenderData().Hidden
+	if !strings.Contains(string(hidden), `<label>Leave this field empty. `+
+		`<input type="text" name="homepage" value="" tabindex="-1" `+
+		`autocomplete="off"/></label>`) {
+		t.Errorf("Hidden should contain the honeypot, got %v", hidden)
+	}
+	match := antiSpamTimeRegexp.FindStringSubmatch(string(hidden))
+	if match == nil {
+		t.Fatalf("Hidden should contain the time, got %v", hidden)
+	}
+	token := match[1]
+	spam := []string{"Your submission looks like spam. Please try again."}
+	tests := []struct {
+		Age    time.Duration
+		Values url.Values
+		Errors []string
+	}{
+		{time.Minute, url.Values{AntiSpamTimeParam: {token}}, nil},
+		{time.Minute, url.Values{AntiSpamTimeParam: {token},
+			"homepage": {""}}, nil},
+		{time.Minute, url.Values{AntiSpamTimeParam: {token},
+			"homepage": {"http://spam.example.com"}}, spam},
+		{time.Second, url.Values{AntiSpamTimeParam: {token}}, spam},
+		{2 * time.Hour, url.Values{AntiSpamTimeParam: {token}},
+			[]string{"Expired!"}},
+		{time.Minute, url.Values{}, spam},
+		{time.Minute, url.Values{AntiSpamTimeParam: {token + "x"}}, spam},
+		{time.Minute, url.Values{AntiSpamTimeParam: {sign([]byte("other"),
+			"antispam:/signup", []byte("12345678"))}}, spam},
+	}
+	for i, test := range tests {
+		now = func() time.Time { return rendered.Add(test.Age) }
+		form := spec.New(&data)
+		test.Values.Set("Name", "Foo")
+		valid, err := form.TryFill(test.Values)
+		if err != nil || valid != (test.Errors == nil) ||
+			!reflect.DeepEqual(form.RenderData().Errors, test.Errors) {
+			t.Errorf("Test %v: TryFill returned %v, %v with errors %q, should "+
+				"return errors %q", i, valid, err, form.RenderData().Errors,
+				test.Errors)
+		}
+	}
+
+	// Times of other forms are rejected, old ones also without MaxAge.
+	now = func() time.Time { return rendered.Add(time.Minute) }
+	form := NewForm(&data, []Field{Field{Id: "Name"}})
+	form.AntiSpam = &AntiSpam{Key: []byte("secret")}
+	form.Action = "/other"
+	if form.Fill(url.Values{AntiSpamTimeParam: {token}}) {
+		t.Errorf("Fill should reject the time of another form")
+	}
+	form.Action = "/signup"
+	if !form.Fill(url.Values{AntiSpamTimeParam: {token}}) {
+		t.Errorf("Fill failed: %v", form.RenderData().Errors)
+	}
+	now = func() time.Time { return rendered.Add(25 * time.Hour) }
+	if form.Fill(url.Values{AntiSpamTimeParam: {token}}) {
+		t.Errorf("Fill should reject times older than 24 hours")
+	}
+
+	form = NewForm(&data, []Field{Field{Id: "Name"}})
+	form.AntiSpam = &AntiSpam{Honeypot: "url"}
+	if _, err := form.TryRenderData(); err == nil {
+		t.Errorf("TryRenderData should fail without a key")
+	}
+	if valid, err := form.TryFill(url.Values{}); valid || err == nil {
+		t.Errorf("TryFill returned %v, %v, should fail without a key", valid,
+			err)
+	}
+	form.AntiSpam.Key = []byte("secret")
+	if hidden := form.RenderData().Hidden; !strings.Contains(string(hidden),
+		`name="url"`) {
+		t.Errorf("Hidden should contain the custom honeypot, got %v", hidden)
+	}
+}
@@ -0,0 +1,297 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CheckError is returned by Form.Check and lists all problems found in the
+// form's definition.
+type CheckError []string
+
+func (e CheckError) Error() string {
+	return "form: " + strings.Join(e, "; ")
+}
+
+// MustNewForm is like NewForm but also checks the fields using Form.Check.
+//
+// It panics if the check fails. It's meant to be used in tests or during
+// initialization to catch misconfigured forms early.
+func MustNewForm(data interface{}, fields []Field) *Form {
+	form := NewForm(data, fields)
+	if err := form.Check(); err != nil {
+		panic(err.Error())
+	}
+	return form
+}
+
+// Check checks the definition of the form against its data.
+//
+// It reports fields which are not present in the data or have a type that
+// can't be bound, duplicated Ids, widgets not suitable for the field's type
+// and fieldsets or formsets referring to unknown fields. Parts of the data
+// which are only known at runtime, e.g. values of maps of interfaces, are
+// skipped.
+//
+// Returns nil or a CheckError.
+func (f *Form) Check() error {
+	var problems CheckError
+	ids := make(map[string]bool)
+	var conditional []Field
+	checkFields := func(fields []Field, prefix string) {
+		for _, field := range fields {
+			if field.Condition != nil {
+				conditional = append(conditional, prefixField(field, prefix))
+			}
+			id := prefix + field.Id
+			if ids[id] {
+				problems = append(problems,
+					fmt.Sprintf("Duplicated field %q", id))
+			}
+			ids[id] = true
+			if problem := f.checkField(field, id); problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+	checkFields(f.Fields, "")
+	fieldsetIds := make(map[string]bool)
+	var checkFieldsets func([]Fieldset)
+	checkFieldsets = func(fieldsets []Fieldset) {
+		for _, fieldset := range fieldsets {
+			for _, id := range fieldset.Fields {
+				switch {
+				case !ids[id]:
+					problems = append(problems,
+						fmt.Sprintf("Fieldset %q contains unknown field %q",
+							fieldset.Legend, id))
+				case fieldsetIds[id]:
+					problems = append(problems,
+						fmt.Sprintf("Field %q is contained in several fieldsets", id))
+				}
+				fieldsetIds[id] = true
+			}
+			checkFieldsets(fieldset.Fieldsets)
+		}
+	}
+	checkFieldsets(f.Fieldsets)
+	for _, formset := range f.Formsets {
+		if ids[formset.Id] {
+			problems = append(problems,
+				fmt.Sprintf("Duplicated field %q", formset.Id))
+		}
+		ids[formset.Id] = true
+		rowsType, err := f.fieldType(formset.Id)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Formset %q: %v",
+				formset.Id, err))
+			continue
+		}
+		if rowsType != nil && rowsType.Kind() != reflect.Slice {
+			problems = append(problems, fmt.Sprintf(
+				"Formset %q is bound to %v instead of a slice", formset.Id,
+				rowsType))
+			continue
+		}
+		checkFields(formset.Fields, formset.rowPrefix(0))
+	}
+	for _, field := range conditional {
+		if !ids[field.Condition.Field] {
+			problems = append(problems, fmt.Sprintf(
+				"Field %q depends on unknown field %q", field.Id,
+				field.Condition.Field))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// bindingProblem checks if the given field with the given full Id can be
+// bound to the form's data.
+//
+// Returns a description of the problem or an empty string.
+func (f *Form) bindingProblem(field Field, id string) string {
+	if composite, ok := field.Widget.(compositeWidget); ok {
+		for _, part := range composite.parts() {
+			if problem := f.bindingProblem(Field{Id: field.Id + "." + part},
+				id+"."+part); problem != "" {
+				return problem
+			}
+		}
+		return ""
+	}
+	target, err := f.fieldType(id)
+	if err != nil {
+		return fmt.Sprintf("Field %q: %v", id, err)
+	}
+	if target != nil && fieldKind(field) != "file" && !convertible(target) {
+		return fmt.Sprintf("Field %q is bound to unsupported type %v", id,
+			target)
+	}
+	return ""
+}
+
+// fieldKind returns the kind of the given field's widget.
+func fieldKind(field Field) string {
+	if field.Widget == nil {
+		return "text"
+	}
+	return widgetKind(field.Widget)
+}
+
+// checkField checks the given field with the given full Id.
+//
+// Returns a description of the problem or an empty string.
+func (f *Form) checkField(field Field, id string) string {
+	if problem := f.bindingProblem(field, id); problem != "" {
+		return problem
+	}
+	target, _ := f.fieldType(id)
+	if target == nil {
+		return ""
+	}
+	switch kind := fieldKind(field); {
+	case kind == "file":
+		if target != fileHeaderType && target != fileHeadersType {
+			return fmt.Sprintf(
+				"Field %q has a FileWidget but is bound to %v instead of %v or %v",
+				id, target, fileHeaderType, fileHeadersType)
+		}
+	case kind == "select" && indirect(target).Kind() != reflect.String:
+		return fmt.Sprintf("Field %q has a SelectWidget but is bound to %v",
+			id, target)
+	case kind == "currency" && !isNumber(indirect(target)):
+		return fmt.Sprintf("Field %q has a CurrencyWidget but is bound to %v",
+			id, target)
+	case (kind == "number" || kind == "range") && !isNumber(indirect(target)):
+		return fmt.Sprintf("Field %q has a %v but is bound to %v", id,
+			indirect(reflect.TypeOf(field.Widget)).Name(), target)
+	case kind == "radio" && indirect(target).Kind() != reflect.String:
+		return fmt.Sprintf("Field %q has a RadioWidget but is bound to %v",
+			id, target)
+	}
+	return ""
+}
+
+// indirect returns the element type of pointer types and the given type
+// otherwise.
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// fieldType returns the type of the field with the given Id in the form's
+// data, see dataType.
+func (f *Form) fieldType(id string) (reflect.Type, error) {
+	value := reflect.ValueOf(f.data)
+	acc := getAccessor(value.Type(), id)
+	if acc.static {
+		return acc.fieldType, acc.err
+	}
+	return dataType(value, acc.parts)
+}
+
+// dataType returns the type of the field at the given path elements starting
+// at the given value.
+//
+// It follows the value as far as possible and its type otherwise. Returns a
+// nil type if the type can only be determined at runtime.
+func dataType(value reflect.Value, parts []string) (reflect.Type, error) {
+	valueType := value.Type()
+	for len(parts) > 0 {
+		part := parts[0]
+		switch valueType.Kind() {
+		case reflect.Ptr:
+			valueType = valueType.Elem()
+			if value.IsValid() && !value.IsNil() {
+				value = value.Elem()
+			} else {
+				value = reflect.Value{}
+			}
+			continue
+		case reflect.Interface:
+			if !value.IsValid() || value.IsNil() {
+				return nil, nil
+			}
+			value = value.Elem()
+			valueType = value.Type()
+			continue
+		case reflect.Struct:
+			structField, ok := valueType.FieldByName(part)
+			if !ok {
+				return nil, fmt.Errorf("%v has no field %q", valueType, part)
+			}
+			if structField.PkgPath != "" {
+				return nil, fmt.Errorf("field %q of %v is not exported", part,
+					valueType)
+			}
+			valueType = structField.Type
+			if value.IsValid() {
+				value = value.FieldByIndex(structField.Index)
+			}
+		case reflect.Map:
+			if valueType.Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("%v has no string keys", valueType)
+			}
+			if value.IsValid() {
+				value = value.MapIndex(
+					reflect.ValueOf(part).Convert(valueType.Key()))
+			}
+			valueType = valueType.Elem()
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("%q is not a valid index into %v", part,
+					valueType)
+			}
+			if valueType.Kind() == reflect.Array && index >= valueType.Len() {
+				return nil, fmt.Errorf("index %v is out of range of %v", index,
+					valueType)
+			}
+			valueType = valueType.Elem()
+			if value.IsValid() && index < value.Len() {
+				value = value.Index(index)
+			} else {
+				value = reflect.Value{}
+			}
+		default:
+			return nil, fmt.Errorf("%v has no field %q", valueType, part)
+		}
+		parts = parts[1:]
+	}
+	if valueType.Kind() == reflect.Interface {
+		if !value.IsValid() || value.IsNil() {
+			return nil, nil
+		}
+		return value.Elem().Type(), nil
+	}
+	return valueType, nil
+}
@@ -0,0 +1,136 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"mime/multipart"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type TestCheckData struct {
+	Name     string
+	Age      int
+	Birthday *time.Time
+	Upload   *multipart.FileHeader
+	Address  *TestAllocAddress
+	Numbers  map[string]int
+	Extra    map[string]interface{}
+	Items    []TestItem
+	Pair     [2]string
+	Complex  complex128
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		Fields    []Field
+		Fieldsets []Fieldset
+		Formsets  []Formset
+		Problems  CheckError
+	}{
+		{Fields: []Field{
+			Field{Id: "Name", Widget: new(SelectWidget)},
+			Field{Id: "Age", Widget: RangeWidget{Min: "0", Max: "150"}},
+			Field{Id: "Birthday", Widget: new(DateWidget)},
+			Field{Id: "Upload", Widget: new(FileWidget)},
+			Field{Id: "Address.City.Name"},
+			Field{Id: "Numbers.Foo"},
+			Field{Id: "Extra.Anything.Goes"},
+			Field{Id: "Items[3].Name"},
+			Field{Id: "Pair.1"}},
+			Fieldsets: []Fieldset{
+				Fieldset{Fields: []string{"Name"}, Fieldsets: []Fieldset{
+					Fieldset{Fields: []string{"Age"}}}}}},
+		{Fields: []Field{
+			Field{Id: "Nmae"},
+			Field{Id: "Age"},
+			Field{Id: "Age", Widget: new(SelectWidget)},
+			Field{Id: "Numbers.Foo", Widget: new(RadioWidget)},
+			Field{Id: "Birthday", Widget: new(CurrencyWidget)},
+			Field{Id: "Pair.0", Widget: new(NumberWidget)},
+			Field{Id: "Name", Widget: new(FileWidget)},
+			Field{Id: "Complex"},
+			Field{Id: "Address.Town"},
+			Field{Id: "Items.first.Name"},
+			Field{Id: "Pair.2"}},
+			Problems: CheckError{
+				`Field "Nmae": form.TestCheckData has no field "Nmae"`,
+				`Duplicated field "Age"`,
+				`Field "Age" has a SelectWidget but is bound to int`,
+				`Field "Numbers.Foo" has a RadioWidget but is bound to int`,
+				`Field "Birthday" has a CurrencyWidget but is bound to *time.Time`,
+				`Field "Pair.0" has a NumberWidget but is bound to string`,
+				`Field "Name" has a FileWidget but is bound to string instead of *multipart.FileHeader or []*multipart.FileHeader`,
+				`Field "Complex" is bound to unsupported type complex128`,
+				`Field "Address.Town": form.TestAllocAddress has no field "Town"`,
+				`Field "Items.first.Name": "first" is not a valid index into []form.TestItem`,
+				`Field "Pair.2": index 2 is out of range of [2]string`}},
+		{Fields: []Field{Field{Id: "Name"}},
+			Fieldsets: []Fieldset{
+				Fieldset{Legend: "A", Fields: []string{"Name", "Age"}},
+				Fieldset{Legend: "B", Fields: []string{"Name"}}},
+			Problems: CheckError{
+				`Fieldset "A" contains unknown field "Age"`,
+				`Field "Name" is contained in several fieldsets`}},
+		{Formsets: []Formset{
+			Formset{Id: "Items", Fields: []Field{
+				Field{Id: "Name"},
+				Field{Id: "Nmae"}}},
+			Formset{Id: "Name"}},
+			Problems: CheckError{
+				`Field "Items.0.Nmae": form.TestItem has no field "Nmae"`,
+				`Formset "Name" is bound to string instead of a slice`}},
+		{Fields: []Field{
+			Field{Id: "Name"},
+			Field{Id: "Age", Condition: &Condition{Field: "Nmae"}}},
+			Formsets: []Formset{
+				Formset{Id: "Items", Fields: []Field{
+					Field{Id: "Name", Condition: &Condition{Field: "Age"}}}}},
+			Problems: CheckError{
+				`Field "Age" depends on unknown field "Nmae"`,
+				`Field "Items.0.Name" depends on unknown field "Items.0.Age"`}},
+	}
+	for i, test := range tests {
+		data := TestCheckData{Extra: map[string]interface{}{}}
+		form := NewForm(&data, test.Fields)
+		form.Fieldsets = test.Fieldsets
+		form.Formsets = test.Formsets
+		err := form.Check()
+		if test.Problems == nil {
+			if err != nil {
+				t.Errorf("Test %v: Check failed: %v", i, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(err, test.Problems) {
+			t.Errorf("Test %v: Check returned\n%v\nshould be\n%v", i, err,
+				test.Problems)
+		}
+	}
+}
+
+func TestMustNewForm(t *testing.T) {
+	data := TestCheckData{}
+	MustNewForm(&data, []Field{Field{Id: "Name"}})
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustNewForm should panic for unknown fields")
+		}
+	}()
+	MustNewForm(&data, []Field{Field{Id: "Unknown"}})
+}
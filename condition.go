@@ -0,0 +1,171 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Condition makes a field depend on the value of another field.
+//
+// A field whose condition is not met is hidden: Submitted values are not
+// bound to it, it's not validated and it's marked as hidden in its render
+// data. The render data contains the
+// condition as data attributes data-condition-field and
+// data-condition-values (a JSON array) to allow toggling the field on the
+// client side.
+type Condition struct {
+	// Field is the Id of the field the condition depends on. Inside
+	// formsets, it's the Id of a field in the same row.
+	Field string
+	// Values contains the values of the other field for which the
+	// condition is met.
+	Values []string
+}
+
+// maxConditionDepth limits the length of chains of conditions.
+const maxConditionDepth = 16
+
+// attrs returns the data attributes describing the condition.
+func (c *Condition) attrs() template.HTMLAttr {
+	if c == nil {
+		return ""
+	}
+	values := c.Values
+	if values == nil {
+		values = []string{}
+	}
+	encoded, _ := json.Marshal(values)
+	return template.HTMLAttr(fmt.Sprintf(
+		`data-condition-field="%v" data-condition-values="%v"`,
+		template.HTMLEscapeString(c.Field),
+		template.HTMLEscapeString(string(encoded))))
+}
+
+// prefixField returns a copy of the given field with the given prefix added
+// to its Id and to the Id of its condition.
+func prefixField(field Field, prefix string) Field {
+	field.Id = prefix + field.Id
+	if field.Condition != nil {
+		condition := *field.Condition
+		condition.Field = prefix + condition.Field
+		field.Condition = &condition
+	}
+	return field
+}
+
+// sortByCondition returns the given fields ordered so that fields come after
+// the fields their conditions depend on, keeping the order otherwise.
+func sortByCondition(fields []Field) []Field {
+	byId := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byId[field.Id] = field
+	}
+	depths := make(map[string]int, len(fields))
+	for _, field := range fields {
+		id, depth := field.Id, 0
+		for ; field.Condition != nil && depth < maxConditionDepth; depth++ {
+			var ok bool
+			if field, ok = byId[field.Condition.Field]; !ok {
+				depth++
+				break
+			}
+		}
+		depths[id] = depth
+	}
+	ret := append([]Field(nil), fields...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return depths[ret[i].Id] < depths[ret[j].Id]
+	})
+	return ret
+}
+
+// lookupField returns the field of the form with the given full Id.
+func (f *Form) lookupField(id string) (Field, bool) {
+	for _, field := range f.Fields {
+		if field.Id == id {
+			return field, true
+		}
+	}
+	for _, formset := range f.Formsets {
+		if !strings.HasPrefix(id, formset.Id+".") {
+			continue
+		}
+		rest := id[len(formset.Id)+1:]
+		dot := strings.IndexByte(rest, '.')
+		if dot < 0 {
+			continue
+		}
+		for _, field := range formset.Fields {
+			if field.Id == rest[dot+1:] {
+				return prefixField(field, id[:len(id)-len(field.Id)]), true
+			}
+		}
+	}
+	return Field{}, false
+}
+
+// hiddenField returns true if the condition of the given field or of any field
+// it depends on is not met.
+func (f *Form) hiddenField(field Field) bool {
+	for depth := 0; field.Condition != nil; depth++ {
+		if depth == maxConditionDepth {
+			return true
+		}
+		value, err := f.getNestedField(field.Condition.Field)
+		if err != nil || !conditionMet(value, field.Condition.Values) {
+			return true
+		}
+		var ok bool
+		if field, ok = f.lookupField(field.Condition.Field); !ok {
+			break
+		}
+	}
+	return false
+}
+
+// conditionMet returns true if the given value matches one of the given
+// values. Slices match if any of their elements matches.
+func conditionMet(value reflect.Value, values []string) bool {
+	for value.IsValid() && (value.Kind() == reflect.Ptr ||
+		value.Kind() == reflect.Interface) {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			if conditionMet(value.Index(i), values) {
+				return true
+			}
+		}
+		return false
+	}
+	formatted := ""
+	if value.IsValid() {
+		formatted = formatValue(value.Interface())
+	}
+	for _, v := range values {
+		if v == formatted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type TestAccountData struct {
+	Type    string
+	Company string
+	VatId   string
+	Roles   []string
+	Admin   *bool
+}
+
+func testAccountForm(data *TestAccountData) *Form {
+	return NewForm(data, []Field{
+		Field{Id: "Type", Widget: &SelectWidget{Options: []Option{
+			{Value: "private", Text: "Private"},
+			{Value: "business", Text: "Business"}}}},
+		Field{Id: "Company", Validator: Required("Req!"),
+			Condition: &Condition{Field: "Type", Values: []string{"business"}}},
+		Field{Id: "VatId", Validator: Required("Req!"),
+			Condition: &Condition{Field: "Company", Values: []string{"ACME"}}},
+		Field{Id: "Admin", Widget: new(HiddenWidget),
+			Condition: &Condition{Field: "Roles", Values: []string{"admin"}}}})
+}
+
+func TestConditionValidate(t *testing.T) {
+	tests := []struct {
+		Values url.Values
+		Valid  bool
+		Hidden []string
+	}{
+		{url.Values{"Type": {"private"}}, true,
+			[]string{"Company", "VatId"}},
+		{url.Values{"Type": {"business"}}, false, []string{"VatId"}},
+		{url.Values{"Type": {"business"}, "Company": {"Foo"}}, true,
+			[]string{"VatId"}},
+		{url.Values{"Type": {"business"}, "Company": {"ACME"}}, false, nil},
+		// VatId is hidden as it depends on the hidden Company field.
+		{url.Values{"Type": {"private"}, "Company": {"ACME"}}, true,
+			[]string{"Company", "VatId"}},
+	}
+	for i, test := range tests {
+		// Admin is shown as Roles contains "admin".
+		data := TestAccountData{Roles: []string{"user", "admin"}}
+		form := testAccountForm(&data)
+		if valid := form.Fill(test.Values); valid != test.Valid {
+			t.Errorf("Test %v: Fill returned %v, should be %v", i, valid,
+				test.Valid)
+		}
+		var hidden []string
+		for _, field := range form.RenderData().Fields {
+			if field.Hidden {
+				hidden = append(hidden, field.Id)
+			}
+		}
+		if strings.Join(hidden, " ") != strings.Join(test.Hidden, " ") {
+			t.Errorf("Test %v: Hidden fields are %v, should be %v", i, hidden,
+				test.Hidden)
+		}
+	}
+}
+
+func TestConditionBinding(t *testing.T) {
+	data := TestAccountData{Company: "Old", VatId: "Old"}
+	form := testAccountForm(&data)
+	// Dependent fields are bound after the fields they depend on.
+	form.Fields = []Field{form.Fields[2], form.Fields[1], form.Fields[0]}
+	if !form.Fill(url.Values{"Type": {"private"}, "Company": {"ACME"},
+		"VatId": {"X"}}) {
+		t.Errorf("Fill failed: %v", form.errors)
+	}
+	if data.Company != "Old" || data.VatId != "Old" {
+		t.Errorf("Inactive fields should not be bound, got %+v", data)
+	}
+	if input := form.RenderData().Fields[1].Input; !strings.Contains(
+		string(input), `value="Old"`) {
+		t.Errorf("Inactive field should render the data, got %v", input)
+	}
+	if !form.Fill(url.Values{"Type": {"business"}, "Company": {"ACME"},
+		"VatId": {"X"}}) {
+		t.Errorf("Fill failed: %v", form.errors)
+	}
+	if data.Company != "ACME" || data.VatId != "X" {
+		t.Errorf("Active fields should be bound, got %+v", data)
+	}
+
+	rows := TestFormsetData{Addresses: []TestAddress{{Number: 1}}}
+	form = NewForm(&rows, nil)
+	formset := testFormset()
+	formset.Fields[1].Condition = &Condition{Field: "Street",
+		Values: []string{"Main"}}
+	form.Formsets = []Formset{formset}
+	form.Fill(url.Values{"Addresses.count": {"1"},
+		"Addresses.0.Street": {"Side"}, "Addresses.0.Number": {"5"}})
+	if rows.Addresses[0].Number != 1 {
+		t.Errorf("Inactive row field should not be bound, got %+v",
+			rows.Addresses[0])
+	}
+}
+
+func TestConditionUnallocated(t *testing.T) {
+	data := struct {
+		Type    string
+		Company *TestAllocAddress
+	}{}
+	structForm := NewForm(&data, nil)
+	mapForm := NewForm(map[string]interface{}{}, nil)
+	for _, form := range []*Form{structForm, mapForm} {
+		form.Fields = []Field{Field{Id: "Type"},
+			Field{Id: "Company.Street", Validator: Required("Req!"),
+				Condition: &Condition{Field: "Type",
+					Values: []string{"business"}}}}
+		valid, err := form.TryFill(url.Values{"Type": {"private"}})
+		if !valid || err != nil {
+			t.Errorf("TryFill with %T returned %v, %v, errors: %v", form.data,
+				valid, err, form.errors)
+		}
+		if form.Fill(url.Values{"Type": {"business"}}) ||
+			len(form.errors["Company.Street"]) != 1 {
+			t.Errorf("Fill with %T should fail, errors: %v", form.data,
+				form.errors)
+		}
+	}
+}
+
+func TestConditionRender(t *testing.T) {
+	data := TestAccountData{Type: "private"}
+	form := testAccountForm(&data)
+	field := form.RenderData().Fields[1]
+	expected := `data-condition-field="Company" ` +
+		`data-condition-values="[&#34;business&#34;]"`
+	if field.Attrs != `data-condition-field="Type" `+
+		`data-condition-values="[&#34;business&#34;]"` {
+		t.Errorf("Attrs = %q", field.Attrs)
+	}
+	if attrs := form.RenderData().Fields[0].Attrs; attrs != "" {
+		t.Errorf("Attrs of unconditional field = %q, should be empty", attrs)
+	}
+	form.Fields[2].Condition.Values = []string{"business"}
+	for name, renderer := range map[string]*TemplateRenderer{
+		"plain": PlainTheme(), "bootstrap": BootstrapTheme(),
+		"tailwind": TailwindTheme()} {
+		ret, err := form.Render(renderer)
+		if err != nil {
+			t.Fatalf("%v: Render failed: %v", name, err)
+		}
+		if !strings.Contains(string(ret), expected+" hidden>") {
+			t.Errorf("%v: Rendered form should contain %q, got\n%v", name,
+				expected, ret)
+		}
+	}
+}
+
+func TestConditionFormset(t *testing.T) {
+	data := TestFormsetData{}
+	form := NewForm(&data, nil)
+	formset := testFormset()
+	formset.Fields[1].Condition = &Condition{Field: "Street",
+		Values: []string{"Main"}}
+	formset.Fields[1].Validator = Required("Req!")
+	form.Formsets = []Formset{formset}
+	valid := form.Fill(url.Values{
+		"Addresses.count":    {"2"},
+		"Addresses.0.Street": {"Main"},
+		"Addresses.1.Street": {"Side"}})
+	if valid {
+		t.Errorf("Fill should fail for the number of the first row")
+	}
+	rows := form.RenderData().Formsets[0].Rows
+	if !rows[1].Fields[1].Hidden || rows[0].Fields[1].Hidden {
+		t.Errorf("Only the number of the second row should be hidden")
+	}
+	if len(rows[0].Fields[1].Errors) != 1 || len(rows[1].Fields[1].Errors) != 0 {
+		t.Errorf("Only the number of the first row should have errors")
+	}
+	if !strings.Contains(string(rows[1].Fields[1].Attrs),
+		`data-condition-field="Addresses.1.Street"`) {
+		t.Errorf("Condition should refer to the row's field, got %q",
+			rows[1].Fields[1].Attrs)
+	}
+}
+
+func TestConditionStrict(t *testing.T) {
+	data := TestAccountData{}
+	form := testAccountForm(&data)
+	form.Strict = &StrictMode{}
+	if !form.Fill(url.Values{"Type": {"private"}}) {
+		t.Errorf("Conditional fields should be optional in strict mode, got %v",
+			form.RenderData().Errors)
+	}
+}
@@ -0,0 +1,92 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"html/template"
+	"reflect"
+	"time"
+)
+
+// DateRange is a range of dates, e.g. a reporting period.
+type DateRange struct {
+	Start, End time.Time
+}
+
+// compositeWidget is implemented by widgets rendering several inputs for
+// the parts of a field's value.
+type compositeWidget interface {
+	Widget
+	// parts returns the names of the parts. The inputs of the parts are
+	// named like the field followed by a dot and the name of the part.
+	parts() []string
+}
+
+// DateRangeWidget renders date inputs for the start and end of a
+// DateRange. It may be used for any struct with time.Time or *time.Time
+// fields Start and End.
+type DateRangeWidget int
+
+func (t DateRangeWidget) HTML(field string, value interface{}) template.HTML {
+	data := WidgetData{Id: field, Widget: t}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	for _, part := range t.parts() {
+		var partValue interface{} = ""
+		if v.Kind() == reflect.Struct {
+			if partField := v.FieldByName(part); partField.IsValid() {
+				partValue = partField.Interface()
+			}
+		}
+		data.Parts = append(data.Parts, WidgetData{Id: field + "." + part,
+			Widget: t, Value: formatTime(partValue, "2006-01-02")})
+	}
+	return renderWidget("daterange", data)
+}
+
+func (t DateRangeWidget) parts() []string {
+	return []string{"Start", "End"}
+}
+
+// RangeOrder creates a Validator to check that the end of a DateRange is
+// not before its start. Ranges with a zero start or end are accepted, use
+// Required to reject them.
+//
+// msg is set as validation error.
+func RangeOrder(msg string) Validator {
+	return func(value interface{}) []string {
+		var dateRange DateRange
+		switch obj := value.(type) {
+		case DateRange:
+			dateRange = obj
+		case *DateRange:
+			if obj == nil {
+				return nil
+			}
+			dateRange = *obj
+		default:
+			return nil
+		}
+		if !dateRange.Start.IsZero() && !dateRange.End.IsZero() &&
+			dateRange.End.Before(dateRange.Start) {
+			return []string{msg}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+type TestPeriodData struct {
+	Period   DateRange
+	Optional *DateRange
+	Invalid  struct{ Start time.Time }
+}
+
+func TestDateRangeWidget(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	newForm := func(data *TestPeriodData) *Form {
+		form := NewForm(data, []Field{
+			Field{Id: "Period", Widget: new(DateRangeWidget),
+				Validator: And(Required("Req!"), RangeOrder("Order!"))},
+			Field{Id: "Optional", Widget: new(DateRangeWidget)}})
+		form.InvalidMsg = "Invalid!"
+		form.Strict = &StrictMode{}
+		return form
+	}
+	data := TestPeriodData{Period: DateRange{Start: start}}
+	fields := newForm(&data).RenderData().Fields
+	expected := `<input id="Period.Start" type="date" name="Period.Start" ` +
+		`value="2026-01-01"/> – <input id="Period.End" type="date" ` +
+		`name="Period.End" value=""/>`
+	if string(fields[0].Input) != expected {
+		t.Errorf("Input is %v, should be %v", fields[0].Input, expected)
+	}
+	expected = `<input id="Optional.Start" type="date" name="Optional.Start" ` +
+		`value=""/> – <input id="Optional.End" type="date" ` +
+		`name="Optional.End" value=""/>`
+	if string(fields[1].Input) != expected {
+		t.Errorf("Input is %v, should be %v", fields[1].Input, expected)
+	}
+	tests := []struct {
+		Values url.Values
+		Error  string
+	}{
+		{url.Values{"Period.Start": {"2026-01-01"},
+			"Period.End": {"2026-03-31"}}, ""},
+		{url.Values{"Period.Start": {"2026-01-01"},
+			"Period.End": {"2025-12-31"}}, "Order!"},
+		{url.Values{"Period.Start": {"2026-01-01"},
+			"Period.End": {"tomorrow"}}, "Invalid!"},
+		{url.Values{"Period.Start": {""}, "Period.End": {""}}, "Req!"},
+	}
+	for i, test := range tests {
+		data := TestPeriodData{}
+		form := newForm(&data)
+		test.Values.Set("Optional.Start", "2026-02-01")
+		test.Values.Set("Optional.End", "")
+		valid := form.Fill(test.Values)
+		errors := form.RenderData().Fields[0].Errors
+		if valid != (test.Error == "") || test.Error != "" &&
+			(len(errors) == 0 || errors[0] != test.Error) {
+			t.Errorf("Test %v: Fill returned %v with errors %v, should fail "+
+				"with %q", i, valid, errors, test.Error)
+		}
+		if data.Optional == nil || !data.Optional.Start.Equal(
+			time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Test %v: Optional is %v", i, data.Optional)
+		}
+	}
+	data = TestPeriodData{}
+	newForm(&data).Fill(url.Values{"Period.Start": {"2026-01-01"},
+		"Period.End": {"2026-03-31"}})
+	if !data.Period.End.Equal(time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Period is %v", data.Period)
+	}
+}
+
+func TestDateRangeCheck(t *testing.T) {
+	form := NewForm(&TestPeriodData{}, []Field{
+		Field{Id: "Invalid", Widget: new(DateRangeWidget)}})
+	expected := `form: Field "Invalid.End": struct { Start time.Time } ` +
+		`has no field "End"`
+	if err := form.Check(); err == nil || err.Error() != expected {
+		t.Errorf("Check returned %v, should be %v", err, expected)
+	}
+}
+
+func TestRangeOrder(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Value interface{}
+		Valid bool
+	}{
+		{DateRange{day, day}, true},
+		{DateRange{day, day.AddDate(0, 0, 1)}, true},
+		{DateRange{day.AddDate(0, 0, 1), day}, false},
+		{&DateRange{day.AddDate(0, 0, 1), day}, false},
+		{DateRange{Start: day}, true},
+		{(*DateRange)(nil), true},
+	}
+	for i, test := range tests {
+		if valid := RangeOrder("Order!")(test.Value) == nil; valid !=
+			test.Valid {
+			t.Errorf("Test %v: RangeOrder(%v) returned %v", i, test.Value,
+				!valid)
+		}
+	}
+}
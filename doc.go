@@ -26,10 +26,11 @@ For each form, you have to define a struct for the form fields:
 Then you can use the form like this:
 	func handle(req Request, res *Response) {
 		data := formData{}
-		form := form.NewForm(&data, form.Fields{
-			"Name": form.Field{G("Name"), "Your Name", form.Required(G("Required.")), nil},
-			"Age": form.Field{G("Age"), "Your Age",
-			form.Required(G("Required.")), nil}})
+		form := form.NewForm(&data, []form.Field{
+			form.Field{Id: "Name", Label: G("Your Name"),
+				Validator: form.Required(G("Required."))},
+			form.Field{Id: "Age", Label: G("Your Age"),
+				Validator: form.Required(G("Required."))}})
 		switch req.Method {
 		case "GET":
 			data.Name = "Default Name"
@@ -67,5 +68,33 @@ Fill the render data into a form template like this (html/template):
 			</div>
 		</fieldset>
 	</form>
+
+Instead of writing a template, forms may be rendered with a single call using
+one of the built-in themes PlainTheme, BootstrapTheme or TailwindTheme:
+	out, err := form.Render(form.BootstrapTheme())
+
+The input html of the themes can be overridden per widget kind:
+	renderer := form.BootstrapTheme()
+	err := renderer.Override("select", `<select class="fancy" ...`)
+
+The built-in widgets are rendered using embedded html/template templates, one
+per widget kind. They can be replaced application wide by loading template
+files which define templates named like the kinds, e.g. "text" or "select":
+	err := form.LoadWidgetTemplates("templates/widgets/*.html")
+
+The options of SelectWidget and RadioWidget may be obtained per request from an
+OptionProvider which gets passed the form's Context. Submitted values which are
+not among the provided options are rejected:
+	form.Context = r.Context()
+
+Numbers, currency amounts and dates in text inputs are formatted and parsed
+according to the conventions of the user if the form has a Locale:
+	form.Locale = &form.GermanLocale
+
+Multi-step flows can be composed of several FormSpecs using a Wizard. The data
+accumulated by the steps is carried between requests by a SignedStore (signed
+hidden field) or a SessionStore:
+	page, err := wizard.Process(&data, r.PostForm)
+Custom templates must render RenderData.Hidden inside the form element.
 */
 package form
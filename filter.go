@@ -0,0 +1,55 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Filter normalizes a submitted value before it's converted and validated,
+// see Field.Filters.
+//
+// Unicode normalization is left to golang.org/x/text/unicode/norm, whose
+// String methods are Filters, e.g. Filter(norm.NFC.String).
+type Filter func(value string) string
+
+// TrimSpace removes leading and trailing white space.
+func TrimSpace(value string) string {
+	return strings.TrimSpace(value)
+}
+
+// CollapseSpace replaces runs of white space by a single space and removes
+// leading and trailing white space.
+func CollapseSpace(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Lower maps the value to lower case.
+func Lower(value string) string {
+	return strings.ToLower(value)
+}
+
+// StripControl removes control characters except for tabs and line breaks.
+func StripControl(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,65 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		Filter  Filter
+		In, Out string
+	}{
+		{TrimSpace, " \t a b \n", "a b"},
+		{CollapseSpace, " a  \t b\n\nc ", "a b c"},
+		{Lower, "ALICE@Example.com", "alice@example.com"},
+		{StripControl, "a\x00b\x1b[1mc\td\r\ne\u0085", "ab[1mc\td\r\ne"},
+	}
+	for i, test := range tests {
+		if ret := test.Filter(test.In); ret != test.Out {
+			t.Errorf("Test %v: Filter(%q) = %q, should be %q", i, test.In,
+				ret, test.Out)
+		}
+	}
+}
+
+func TestFillFilters(t *testing.T) {
+	data := struct {
+		Email, Name string
+		Age         int
+	}{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Email", Filters: []Filter{TrimSpace, Lower}},
+		Field{Id: "Name", Validator: Required("Req!"),
+			Filters: []Filter{CollapseSpace, StripControl}},
+		Field{Id: "Age", Filters: []Filter{TrimSpace}}})
+	if form.Fill(url.Values{"Email": {" ALICE@example.com "},
+		"Name": {"   "}, "Age": {" 42 "}}) {
+		t.Errorf("Fill should fail for a blank required field")
+	}
+	if data.Email != "alice@example.com" || data.Age != 42 {
+		t.Errorf("Filters not applied, got %+v", data)
+	}
+	if !form.Fill(url.Values{"Name": {" Ren\x00\u00e9e  Doe "}}) {
+		t.Errorf("Fill failed: %v", form.errors)
+	}
+	if data.Name != "Ren\u00e9e Doe" {
+		t.Errorf("Name = %q, should be filtered", data.Name)
+	}
+}
@@ -17,10 +17,13 @@
 package form
 
 import (
+	"context"
 	"encoding"
+	"errors"
 	"fmt"
-	"html"
 	"html/template"
+	"math"
+	"mime/multipart"
 	"net/url"
 	"reflect"
 	"regexp"
@@ -31,10 +34,17 @@ import (
 
 // FieldRenderData contains the data needed for field rendering.
 type FieldRenderData struct {
+	// Id is the field's Id.
+	Id string
+	// Kind is the kind of the field's widget, e.g. "text" or "select".
+	Kind string
+	// Value is the field's current value.
+	Value interface{}
 	// Lebel is the field's label.
 	Label string
 	// LabelTag is the html code for the field's label, e.g.
-	// `<label for="the_id">The Label</label>`.
+	// `<label for="the_id">The Label</label>`. The label of a radio button
+	// group is for its first button, themes render a legend instead.
 	LabelTag template.HTML
 	// Input is the input html for the field.
 	Input template.HTML
@@ -42,172 +52,125 @@ type FieldRenderData struct {
 	Help string
 	// Errors contains any validation errors.
 	Errors []string
+	// Hidden is true if the field's condition is not met.
+	Hidden bool
+	// Attrs contains attributes for the element containing the field, e.g.
+	// data attributes describing the field's condition for client-side
+	// toggling.
+	Attrs template.HTMLAttr
+}
+
+// FieldsetRenderData contains the data needed for fieldset rendering.
+type FieldsetRenderData struct {
+	Legend, Description string
+	// Fields contains the fields of the set in order.
+	Fields []FieldRenderData
+	// Fieldsets contains nested fieldsets.
+	Fieldsets []FieldsetRenderData
 }
 
 // RenderData contains the data needed for form rendering.
 type RenderData struct {
+	// Fields contains all fields of the form, including the ones grouped
+	// in Fieldsets.
 	Fields []FieldRenderData
-	Errors []string
+	// Fieldsets contains the grouped fields if the form defines fieldsets.
+	Fieldsets []FieldsetRenderData
+	// Ungrouped contains the fields which are not contained in any of the
+	// Fieldsets, e.g. hidden fields, if the form defines fieldsets.
+	Ungrouped []FieldRenderData
+	// Formsets contains the rows of the form's formsets.
+	Formsets []FormsetRenderData
+	Errors   []string
 	// EncTypeAttr is set to 'enctype="multipart/form-data"' if the Form
 	// contains a File widget. Should be used as optional attribute for the form
 	// element if the form may contain file input elements.
 	EncTypeAttr template.HTMLAttr
 	Action      string
+	// Hidden contains additional hidden inputs, e.g. the state of a wizard
+	// or the fields of the spam protection.
+	// It must be rendered inside the form element.
+	Hidden template.HTML
 }
 
 type Widget interface {
 	HTML(name string, value interface{}) template.HTML
 }
 
-// timeConverter converts a string to a time.Time
-func timeConverter(in string) reflect.Value {
-	out, err := time.Parse(time.RFC3339, in)
-	if err != nil {
-		out, err = time.Parse("2006-01-02", in)
-	}
-	if err != nil {
-		out, _ = time.Parse("15:04:05", in)
-	}
-	return reflect.ValueOf(out)
+// timeLayout is a layout accepted for time.Time fields.
+type timeLayout struct {
+	layout string
+	// inLocation is true if values of the layout lack an offset and are
+	// interpreted in the form's location.
+	inLocation bool
 }
 
-type DateTimeWidget int
-
-func (t DateTimeWidget) HTML(field string, value interface{}) template.HTML {
-	var out string
-	if obj, ok := value.(time.Time); ok {
-		out = obj.Format(time.RFC3339)
-	} else if obj, ok := value.(*time.Time); ok {
-		if obj == nil {
-			out = ""
-		} else {
-			out = obj.Format(time.RFC3339)
-		}
-	} else {
-		out = fmt.Sprintf("%v", obj)
-	}
-	return template.HTML(fmt.Sprintf(
-		`<input id="%v" type="datetime" name="%v" value="%v"/>`,
-		field, field, html.EscapeString(out)))
+// timeLayouts contains the layouts accepted for time.Time fields, i.e. the
+// formats of the date and time widgets. Values of week inputs are parsed by
+// parseWeek.
+var timeLayouts = []timeLayout{
+	{time.RFC3339, false},
+	// Local times of datetime-local inputs.
+	{"2006-01-02T15:04:05", true},
+	{"2006-01-02T15:04", true},
+	{"2006-01-02", false},
+	{"15:04:05", false},
+	{"15:04", false},
+	{"2006-01", false},
 }
 
-type DateWidget int
-
-func (t DateWidget) HTML(field string, value interface{}) template.HTML {
-	var out string
-	if obj, ok := value.(time.Time); ok {
-		out = obj.Format("2006-01-02")
-	} else if obj, ok := value.(*time.Time); ok {
-		if obj == nil {
-			out = ""
+// timeConverter converts a string to a time.Time using the first matching
+// layout of timeLayouts.
+//
+// Dates with a time but without an offset are interpreted in the given
+// location.
+func timeConverter(in string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		var out time.Time
+		var err error
+		if layout.inLocation {
+			out, err = time.ParseInLocation(layout.layout, in, loc)
 		} else {
-			out = obj.Format("2006-01-02")
+			out, err = time.Parse(layout.layout, in)
 		}
-	} else {
-		out = fmt.Sprintf("%v", obj)
-	}
-	return template.HTML(fmt.Sprintf(
-		`<input id="%v" type="date" name="%v" value="%v"/>`,
-		field, field, html.EscapeString(out)))
-}
-
-type TimeWidget int
-
-func (t TimeWidget) HTML(field string, value interface{}) template.HTML {
-	var out string
-	if obj, ok := value.(time.Time); ok {
-		out = obj.Format("15:04:05")
-	} else if obj, ok := value.(*time.Time); ok {
-		if obj == nil {
-			out = ""
-		} else {
-			out = obj.Format("15:04:05")
+		if err == nil {
+			return out, nil
 		}
-	} else {
-		out = fmt.Sprintf("%v", obj)
 	}
-	return template.HTML(fmt.Sprintf(
-		`<input id="%v" type="time" name="%v" value="%v"/>`,
-		field, field, html.EscapeString(out)))
-}
-
-type Text int
-
-func (t Text) HTML(field string, value interface{}) template.HTML {
-	return template.HTML(fmt.Sprintf(
-		`<input id="%v" type="text" name="%v" value="%v"/>`,
-		field, field, html.EscapeString(
-			fmt.Sprintf("%v", value))))
-}
-
-type AlohaEditor int
-
-func (t AlohaEditor) HTML(field string, value interface{}) template.HTML {
-	return template.HTML(fmt.Sprintf(
-		`<textarea class="editor" id="%v" name="%v"/>%v</textarea>`,
-		field, field, html.EscapeString(
-			fmt.Sprintf("%v", value))))
-}
-
-type TextArea int
-
-func (t TextArea) HTML(field string, value interface{}) template.HTML {
-	return template.HTML(fmt.Sprintf(
-		`<textarea id="%v" name="%v"/>%v</textarea>`,
-		field, field, html.EscapeString(
-			fmt.Sprintf("%v", value))))
-}
-
-// Option of a select widget.
-type Option struct {
-	Value, Text string
+	if out, ok := parseWeek(in); ok {
+		return out, nil
+	}
+	return time.Time{}, &inputError{fmt.Errorf("invalid time %q", in)}
 }
 
-// SelectWidget renders a selection field.
-type SelectWidget struct {
-	Options []Option
-}
+// weekRegexp matches the values of week inputs, e.g. "2026-W42".
+var weekRegexp = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
 
-func (t SelectWidget) HTML(field string, value interface{}) template.HTML {
-	var options string
-	for _, v := range t.Options {
-		selected := ""
-		if v.Value == value.(string) {
-			selected = " selected"
-		}
-		options += fmt.Sprintf("<option value=\"%v\"%v>%v</option>\n",
-			v.Value, selected, v.Text)
+// parseWeek returns the monday of the given ISO 8601 week.
+func parseWeek(in string) (time.Time, bool) {
+	match := weekRegexp.FindStringSubmatch(in)
+	if match == nil {
+		return time.Time{}, false
+	}
+	year, _ := strconv.Atoi(match[1])
+	week, _ := strconv.Atoi(match[2])
+	// January 4th is always in the first week.
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+7*(week-1))
+	if y, w := monday.ISOWeek(); y != year || w != week {
+		return time.Time{}, false
 	}
-	ret := fmt.Sprintf("<select id=\"%v\" name=\"%v\">\n%v</select>",
-		field, field, options)
-	return template.HTML(ret)
+	return monday, true
 }
 
-// HiddenWidget renders a hidden input field.
-type HiddenWidget int
-
-func (t HiddenWidget) HTML(field string, value interface{}) template.HTML {
-	return template.HTML(
-		fmt.Sprintf(`<input id="%v" type="hidden" name="%v" value="%v"/>`,
-			field, field, value))
+// inputError is returned by stringToValue for submitted values which can't
+// be parsed.
+type inputError struct {
+	err error
 }
 
-// PasswordWidget renders a password field.
-type PasswordWidget int
-
-func (t PasswordWidget) HTML(field string, value interface{}) template.HTML {
-	return template.HTML(
-		fmt.Sprintf(`<input id="%v" type="password" name="%v"/>`,
-			field, field))
-}
-
-// FileWidget renders a file upload field.
-type FileWidget int
-
-func (t FileWidget) HTML(field string, value interface{}) template.HTML {
-	return template.HTML(
-		fmt.Sprintf(`<input id="%v" type="file" name="%v"/>`,
-			field, field))
+func (e *inputError) Error() string {
+	return "form: " + e.err.Error()
 }
 
 // Field contains settings for a form field.
@@ -215,15 +178,74 @@ type Field struct {
 	Id, Label, Help string
 	Validator       Validator
 	Widget          Widget
+	// Condition optionally makes the field depend on the value of another
+	// field.
+	Condition *Condition
+	// Filters are applied in order to the submitted values of the field
+	// before they are converted and validated, e.g. TrimSpace.
+	Filters []Filter
+}
+
+// Fieldset groups fields of a form into a section.
+type Fieldset struct {
+	// Legend is the title of the section, Description an optional text
+	// describing it.
+	Legend, Description string
+	// Fields contains the Ids of the fields in the set in the order they
+	// should be rendered.
+	Fields []string
+	// Fieldsets contains nested fieldsets, rendered after Fields.
+	Fieldsets []Fieldset
 }
 
 // Form represents an html form.
 type Form struct {
 	Fields []Field
-	data   interface{}
-	errors map[string][]string
+	// Fieldsets optionally groups the fields into sections.
+	//
+	// The built-in themes render the fields not contained in any fieldset
+	// after the fieldsets.
+	Fieldsets []Fieldset
+	// Formsets contains repeating sub-forms bound to slices of structs.
+	Formsets []Formset
+	data     interface{}
+	errors   map[string][]string
 	// Action defines the action parameter of the HTML form
 	Action string
+	// Strict enables strict binding of submitted parameters if not nil.
+	Strict *StrictMode
+	// Locale optionally configures the formatting and parsing of numbers
+	// and dates in text and currency inputs.
+	Locale *Locale
+	// Location is the time zone of the values of DateTimeWidgets. They are
+	// rendered in it and submitted local times are interpreted in it.
+	// Local times which are ambiguous or skipped due to daylight saving
+	// time transitions are resolved as by time.Date. Defaults to UTC if nil.
+	Location *time.Location
+	// InvalidMsg is the error for submitted values which can't be parsed,
+	// e.g. malformed dates. Defaults to "Invalid value." if empty.
+	InvalidMsg string
+	// AntiSpam enables the protection against spam bots if not nil.
+	AntiSpam *AntiSpam
+	// Validators validate the form's data as a whole after the fields have
+	// been validated.
+	Validators []FormValidator
+	// Context is passed to the OptionProviders of the form's widgets.
+	// Defaults to context.Background() if nil.
+	Context context.Context
+	// hidden is rendered as RenderData.Hidden.
+	hidden template.HTML
+	// invalid contains the Ids of fields whose submitted values couldn't be
+	// parsed.
+	invalid map[string]bool
+	// raw contains the values submitted for each field Id. They are
+	// rendered instead of the data's values for fields with errors.
+	raw map[string][]string
+	// files contains the uploaded files during TryFillMultipart.
+	files map[string][]*multipart.FileHeader
+	// reserved contains parameters used internally, e.g. by wizards, which
+	// are accepted in strict mode.
+	reserved []string
 }
 
 // NewForm creates a new Form with the given fields with data stored in the
@@ -231,49 +253,185 @@ type Form struct {
 //
 // In panics if data is not a pointer to a struct.
 func NewForm(data interface{}, fields []Field) *Form {
-	if dataType := reflect.TypeOf(data); (dataType.Kind() != reflect.Ptr ||
+	form, err := TryNewForm(data, fields)
+	if err != nil {
+		panic(err.Error())
+	}
+	return form
+}
+
+// TryNewForm is like NewForm but returns an error instead of panicking if
+// data is not a map or a pointer to a struct.
+func TryNewForm(data interface{}, fields []Field) (*Form, error) {
+	if err := checkData(data); err != nil {
+		return nil, err
+	}
+	form := Form{data: data, Fields: fields}
+	return &form, nil
+}
+
+// checkData returns an error if data is not a map or a pointer to a struct.
+func checkData(data interface{}) error {
+	dataType := reflect.TypeOf(data)
+	if dataType == nil || (dataType.Kind() != reflect.Ptr ||
 		dataType.Elem().Kind() != reflect.Struct) &&
 		dataType.Kind() != reflect.Map {
-		panic("NewForm(data, fields) expects data to be a map or a pointer to a struct.")
+		return fmt.Errorf(
+			"form: Expected data to be a map or a pointer to a struct, got %T",
+			data)
+	}
+	if reflect.ValueOf(data).IsNil() {
+		return fmt.Errorf("form: Expected data to be non nil")
 	}
-	form := Form{data: data, Fields: fields,
-		errors: make(map[string][]string, len(fields))}
-	return &form
+	return nil
 }
 
 // RenderData returns a RenderData struct for the form.
 //
-// It panics if a registered field is not present in the data struct.
-func (f Form) RenderData() (renderData RenderData) {
+// Widgets whose options could not be obtained from their OptionProvider are
+// rendered without options. Use TryRenderData to handle such errors.
+func (f Form) RenderData() RenderData {
+	renderData, _ := f.TryRenderData()
+	return renderData
+}
+
+// TryRenderData is like RenderData but returns the first error of an
+// OptionProvider.
+func (f Form) TryRenderData() (renderData RenderData, firstErr error) {
 	renderData.Action = f.Action
 	renderData.Fields = make([]FieldRenderData, 0)
 	for _, field := range f.Fields {
-		widget := field.Widget
-		if widget == nil {
-			widget = new(Text)
-		} else if _, ok := widget.(*FileWidget); ok {
+		fieldData, err := f.fieldRenderData(field)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if fieldData.Kind == "file" {
 			renderData.EncTypeAttr = `enctype="multipart/form-data"`
 		}
-		value, err := f.getNestedField(field.Id)
-		if err != nil {
-			value = reflect.ValueOf("")
+		renderData.Fields = append(renderData.Fields, fieldData)
+	}
+	for _, formset := range f.Formsets {
+		formsetData, err := f.formsetRenderData(formset)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for _, row := range formsetData.Rows {
+			for _, field := range row.Fields {
+				if field.Kind == "file" {
+					renderData.EncTypeAttr = `enctype="multipart/form-data"`
+				}
+			}
+		}
+		renderData.Formsets = append(renderData.Formsets, formsetData)
+	}
+	if len(f.Fieldsets) > 0 {
+		fields := make(map[string]FieldRenderData, len(renderData.Fields))
+		for _, field := range renderData.Fields {
+			fields[field.Id] = field
+		}
+		renderData.Fieldsets = fieldsetRenderData(f.Fieldsets, fields)
+		grouped := make(map[string]bool)
+		var addGrouped func([]Fieldset)
+		addGrouped = func(fieldsets []Fieldset) {
+			for _, fieldset := range fieldsets {
+				for _, id := range fieldset.Fields {
+					grouped[id] = true
+				}
+				addGrouped(fieldset.Fieldsets)
+			}
+		}
+		addGrouped(f.Fieldsets)
+		for _, field := range renderData.Fields {
+			if !grouped[field.Id] {
+				renderData.Ungrouped = append(renderData.Ungrouped, field)
+			}
 		}
-		renderData.Fields = append(renderData.Fields, FieldRenderData{
-			Label: field.Label,
-			LabelTag: template.HTML(fmt.Sprintf(`<label for="%v">%v</label>`,
-				field.Id, field.Label)),
-			Input:  widget.HTML(field.Id, value.Interface()),
-			Help:   field.Help,
-			Errors: f.errors[field.Id]})
 	}
 	renderData.Errors = f.errors[""]
+	renderData.Hidden = f.hidden
+	if f.AntiSpam != nil {
+		hidden, err := f.AntiSpam.hidden(f.Action)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		renderData.Hidden += hidden
+	}
 	return
 }
 
+// fieldRenderData returns the render data of the given field.
+//
+// Returns an error if the options of the field's widget could not be
+// obtained.
+func (f Form) fieldRenderData(field Field) (FieldRenderData, error) {
+	widget, optionsErr := f.resolveWidget(field.Widget)
+	value, err := f.getNestedField(field.Id)
+	if err != nil || !value.IsValid() || (value.Kind() == reflect.Ptr ||
+		value.Kind() == reflect.Interface) && value.IsNil() {
+		value = reflect.ValueOf("")
+	}
+	if widgetKind(widget) == "datetime" {
+		value = reflect.ValueOf(inLocation(value.Interface(), f.location()))
+	}
+	input := value.Interface()
+	if formatted, ok := f.Locale.format(input, widget); ok {
+		input = formatted
+	}
+	if raw := f.raw[field.Id]; len(f.errors[field.Id]) > 0 &&
+		len(raw) == 1 && widgetKind(widget) != "file" {
+		// Show the submitted value to let the user correct it.
+		input = raw[0]
+	}
+	labelFor := field.Id
+	if widgetKind(widget) == "radio" {
+		labelFor += "-0"
+	}
+	return FieldRenderData{
+		Id:    field.Id,
+		Kind:  widgetKind(widget),
+		Value: value.Interface(),
+		Label: field.Label,
+		LabelTag: template.HTML(fmt.Sprintf(`<label for="%v">%v</label>`,
+			labelFor, field.Label)),
+		Input:  widget.HTML(field.Id, input),
+		Help:   field.Help,
+		Errors: f.errors[field.Id],
+		Hidden: f.hiddenField(field),
+		Attrs:  field.Condition.attrs()}, optionsErr
+}
+
+// fieldsetRenderData returns the render data of the given fieldsets using
+// the given render data of the form's fields.
+//
+// Unknown field Ids are ignored.
+func fieldsetRenderData(fieldsets []Fieldset,
+	fields map[string]FieldRenderData) []FieldsetRenderData {
+	ret := make([]FieldsetRenderData, 0, len(fieldsets))
+	for _, fieldset := range fieldsets {
+		data := FieldsetRenderData{
+			Legend:      fieldset.Legend,
+			Description: fieldset.Description,
+			Fields:      make([]FieldRenderData, 0, len(fieldset.Fields))}
+		for _, id := range fieldset.Fields {
+			if field, ok := fields[id]; ok {
+				data.Fields = append(data.Fields, field)
+			}
+		}
+		if len(fieldset.Fieldsets) > 0 {
+			data.Fieldsets = fieldsetRenderData(fieldset.Fieldsets, fields)
+		}
+		ret = append(ret, data)
+	}
+	return ret
+}
+
 // AddError adds an error to a field's error list.
 //
 // To add global form errors, use an empty string as the field's name.
 func (f *Form) AddError(field string, error string) {
+	if f.errors == nil {
+		f.errors = make(map[string][]string)
+	}
 	if f.errors[field] == nil {
 		f.errors[field] = make([]string, 0, 1)
 	}
@@ -296,147 +454,587 @@ func (f Form) getNestedField(field string) (reflect.Value, error) {
 	return f.findNestedField(field, nil)
 }
 
+// dataField returns the value of the field with the given Id in the given
+// data.
+func dataField(data interface{}, field string) (reflect.Value, error) {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return reflect.Value{}, invalidField(field)
+	}
+	return walkField(value, getAccessor(value.Type(), field), 0, nil, field)
+}
+
+// maxSliceLen limits the length up to which slices get grown by submitted
+// data.
+const maxSliceLen = 1000
+
+// indexRegexp matches indices given in bracket syntax.
+var indexRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
+// splitField splits the given field Id into its path elements.
+//
+// Indices may be given in bracket syntax, i.e. "Items[2].Name" is the same as
+// "Items.2.Name".
+func splitField(field string) []string {
+	return strings.Split(indexRegexp.ReplaceAllString(field, ".$1"), ".")
+}
+
+// setter returns the value to be set to a field of the given type.
+type setter func(target reflect.Type) (interface{}, error)
+
 // findNestedField searches for the given field in the form data.
 //
-// If setValue is given, it will be set to the field.
-func (f *Form) findNestedField(field string, setValue interface{}) (reflect.Value, error) {
-	parts := strings.Split(field, ".")
+// If set is given, the field will be set to the value it returns for the
+// field's type. Nil pointers, nil maps and missing map entries along the
+// path get allocated and slices get grown as needed. Missing entries of maps
+// with interface values are allocated as map[string]interface{}, or as
+// string for the field itself.
+func (f *Form) findNestedField(field string, set setter) (reflect.Value, error) {
 	value := reflect.ValueOf(f.data)
-	for len(parts) != 0 {
-		setIt := len(parts) == 1 && setValue != nil
-		part := parts[0]
-		switch value.Type().Kind() {
-		case reflect.Ptr, reflect.Interface:
+	return walkField(value, getAccessor(value.Type(), field), 0, set, field)
+}
+
+// invalidField returns the error for an invalid field.
+func invalidField(field string) error {
+	return fmt.Errorf("form: Invalid field %q in data", field)
+}
+
+// walkField follows the path elements of the given accessor starting at the
+// given value and path element.
+//
+// See findNestedField for a description of set. field is the Id of the
+// field, used for error messages.
+func walkField(value reflect.Value, acc *accessor, i int, set setter,
+	field string) (reflect.Value, error) {
+	if i == len(acc.parts) {
+		if set != nil {
+			if !value.CanSet() {
+				return reflect.Value{},
+					fmt.Errorf("form: Can't set field %q in data", field)
+			}
+			target := value.Type()
+			if value.Kind() == reflect.Interface && !value.IsNil() {
+				target = value.Elem().Type()
+			}
+			setValue, err := set(target)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if err := assignValue(value, setValue); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+		if !value.CanInterface() {
+			return reflect.Value{},
+				fmt.Errorf("form: Can't access field %q in data", field)
+		}
+		if value.Kind() == reflect.Interface {
 			value = value.Elem()
-			continue
-		case reflect.Struct:
-			value = value.FieldByName(part)
-		case reflect.Map:
-			if setIt {
-				value.SetMapIndex(reflect.ValueOf(part), reflect.ValueOf(setValue))
-				return reflect.Value{}, nil
+		}
+		return value, nil
+	}
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			if set == nil || !value.CanSet() {
+				return reflect.Value{}, invalidField(field)
+			}
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return walkField(value.Elem(), acc, i, set, field)
+	case reflect.Interface:
+		if value.IsNil() {
+			if set == nil || !value.CanSet() {
+				return reflect.Value{}, invalidField(field)
 			}
-			value = value.MapIndex(reflect.ValueOf(part))
-		default:
+			value.Set(reflect.ValueOf(make(map[string]interface{})))
+		}
+		elem := value.Elem()
+		if set == nil || elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Map {
+			return walkField(elem, acc, i, set, field)
+		}
+		// The element of an interface is not settable, so work on a copy.
+		elemCopy := reflect.New(elem.Type()).Elem()
+		elemCopy.Set(elem)
+		if _, err := walkField(elemCopy, acc, i, set, field); err != nil {
+			return reflect.Value{}, err
+		}
+		if !value.CanSet() {
+			return reflect.Value{},
+				fmt.Errorf("form: Can't set field %q in data", field)
+		}
+		value.Set(elemCopy)
+		return reflect.Value{}, nil
+	case reflect.Struct:
+		index := acc.fields[i]
+		if index == nil {
+			var ok bool
+			if index, ok = structFieldIndex(value.Type(), acc.parts[i]); !ok {
+				return reflect.Value{}, invalidField(field)
+			}
+		}
+		fieldValue, err := value.FieldByIndexErr(index)
+		if err != nil {
+			return reflect.Value{}, invalidField(field)
+		}
+		return walkField(fieldValue, acc, i+1, set, field)
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
 			return reflect.Value{},
 				fmt.Errorf("form: Can't find field %q in data", field)
 		}
-		if !value.IsValid() {
+		key := reflect.ValueOf(acc.parts[i]).Convert(value.Type().Key())
+		if value.IsNil() && set != nil {
+			if !value.CanSet() {
+				return reflect.Value{}, invalidField(field)
+			}
+			value.Set(reflect.MakeMap(value.Type()))
+		}
+		elem := value.MapIndex(key)
+		if set == nil {
+			if !elem.IsValid() {
+				return reflect.Value{}, invalidField(field)
+			}
+			return walkField(elem, acc, i+1, nil, field)
+		}
+		if i == len(acc.parts)-1 && value.Type().Elem().Kind() == reflect.Interface {
+			target := reflect.TypeOf("")
+			if elem.IsValid() && !elem.IsNil() {
+				target = elem.Elem().Type()
+			}
+			setValue, err := set(target)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value.SetMapIndex(key, reflect.ValueOf(setValue))
+			return reflect.Value{}, nil
+		}
+		if !elem.IsValid() {
+			elem = reflect.Zero(value.Type().Elem())
+		}
+		// Map elements are not settable, so work on a copy.
+		elemCopy := reflect.New(value.Type().Elem()).Elem()
+		elemCopy.Set(elem)
+		if _, err := walkField(elemCopy, acc, i+1, set, field); err != nil {
+			return reflect.Value{}, err
+		}
+		value.SetMapIndex(key, elemCopy)
+		return reflect.Value{}, nil
+	case reflect.Slice, reflect.Array:
+		index, err := strconv.Atoi(acc.parts[i])
+		if err != nil || index < 0 {
 			return reflect.Value{},
-				fmt.Errorf("form: Invalid field %q in data", field)
+				fmt.Errorf("form: Invalid index in field %q", field)
 		}
-		parts = parts[1:]
-	}
-	if setValue != nil {
-		if value.Type().Kind() == reflect.Ptr {
-			v := reflect.New(value.Type().Elem())
-			v.Elem().Set(reflect.ValueOf(setValue))
-			value.Set(v)
-		} else {
-			value.Set(reflect.ValueOf(setValue))
+		if index >= value.Len() {
+			if set == nil || value.Kind() == reflect.Array ||
+				index >= maxSliceLen || !value.CanSet() {
+				return reflect.Value{},
+					fmt.Errorf("form: Invalid index in field %q", field)
+			}
+			growth := index + 1 - value.Len()
+			value.Set(reflect.AppendSlice(value,
+				reflect.MakeSlice(value.Type(), growth, growth)))
 		}
+		return walkField(value.Index(index), acc, i+1, set, field)
+	}
+	return reflect.Value{}, fmt.Errorf("form: Can't find field %q in data", field)
+}
+
+// assignValue sets the given value to dst.
+//
+// If dst is a pointer and the value is not, dst is set to a pointer to a
+// copy of the value.
+func assignValue(dst reflect.Value, value interface{}) error {
+	src := reflect.ValueOf(value)
+	if !src.IsValid() {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
 	}
-	if value.Type().Kind() == reflect.Interface {
-		value = value.Elem()
+	if dst.Kind() == reflect.Ptr && !src.Type().AssignableTo(dst.Type()) &&
+		src.Type().AssignableTo(dst.Type().Elem()) {
+		ptr := reflect.New(dst.Type().Elem())
+		ptr.Elem().Set(src)
+		src = ptr
 	}
-	return value, nil
+	if !src.Type().AssignableTo(dst.Type()) {
+		return fmt.Errorf("form: Can't assign %v to %v", src.Type(), dst.Type())
+	}
+	dst.Set(src)
+	return nil
+}
+
+var (
+	// timeType is the type of time.Time.
+	timeType = reflect.TypeOf(time.Time{})
+	// durationType is the type of time.Duration.
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// textUnmarshalerType is the type of encoding.TextUnmarshaler.
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// convertible returns true iff stringToValue supports the given target type.
+func convertible(target reflect.Type) bool {
+	if target.Implements(textUnmarshalerType) || indirect(target) == timeType {
+		return true
+	}
+	if target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	switch target.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8,
+		reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
 }
 
 // stringToValue converts the given source string to a value of the
-// given target type.
-func stringToValue(src string, target reflect.Type) interface{} {
-	if target.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
-		if target.Kind() == reflect.Ptr {
-			target = target.Elem()
+// given target type. Local times are interpreted in the given location.
+//
+// Returns an error if the type is not supported, see convertible.
+func stringToValue(src string, target reflect.Type, loc *time.Location) (
+	interface{}, error) {
+	if src == "" && target.Kind() == reflect.Ptr {
+		// Empty values, e.g. of a select widget's placeholder, unset
+		// pointers.
+		return reflect.Zero(target).Interface(), nil
+	}
+	if indirect(target) == timeType {
+		if src == "" {
+			return time.Time{}, nil
 		}
+		return timeConverter(src, loc)
+	}
+	if indirect(target) == durationType {
+		if src == "" {
+			return time.Duration(0), nil
+		}
+		duration, err := time.ParseDuration(src)
+		if err != nil {
+			return nil, &inputError{fmt.Errorf("invalid duration %q", src)}
+		}
+		return duration, nil
+	}
+	if target.Implements(textUnmarshalerType) {
+		target = indirect(target)
 		val := reflect.New(target)
 		val.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(src))
-		return val.Elem().Interface()
-	}
-	if target.Kind() == reflect.Ptr {
-		target = target.Elem()
+		return val.Elem().Interface(), nil
 	}
+	target = indirect(target)
+	var value interface{}
 	switch target.Kind() {
 	case reflect.String:
-		return src
-	case reflect.Int:
-		v, err := strconv.ParseInt(src, 0, 0)
+		value = src
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		if src == "" {
+			value = 0
+			break
+		}
+		v, err := strconv.ParseInt(src, 10, target.Bits())
+		if err != nil {
+			return nil, &inputError{fmt.Errorf("invalid number %q", src)}
+		}
+		value = v
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		if src == "" {
+			value = 0
+			break
+		}
+		v, err := strconv.ParseUint(src, 10, target.Bits())
 		if err != nil {
-			return 0
+			return nil, &inputError{fmt.Errorf("invalid number %q", src)}
 		}
-		return int(v)
+		value = v
+	case reflect.Float32, reflect.Float64:
+		if src == "" {
+			value = 0.0
+			break
+		}
+		v, err := strconv.ParseFloat(src, target.Bits())
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, &inputError{fmt.Errorf("invalid number %q", src)}
+		}
+		value = v
 	case reflect.Bool:
 		v, err := strconv.ParseBool(src)
 		if err != nil {
-			return false
+			v = false
 		}
-		return v
+		value = v
 	default:
-		panic(fmt.Sprintln("form: Unknown field kind", target.Kind()))
+		return nil, fmt.Errorf("form: Unsupported field type %v", target)
+	}
+	return reflect.ValueOf(value).Convert(target).Interface(), nil
+}
+
+// location returns the form's Location.
+func (f Form) location() *time.Location {
+	if f.Location == nil {
+		return time.UTC
+	}
+	return f.Location
+}
+
+// setNestedField searches for the given nested field in the given data and
+// sets it to the given value.
+//
+// The value is parsed according to the form's Locale if the given widget
+// is localized. Values which can't be parsed are reported as field errors by
+// validate.
+func (f *Form) setNestedField(field string, widget Widget,
+	value string) error {
+	_, err := f.findNestedField(field, func(target reflect.Type) (
+		interface{}, error) {
+		value, err := f.Locale.delocalize(sanitize(widget, value), target,
+			widget)
+		if err != nil {
+			return nil, err
+		}
+		return stringToValue(value, target, f.location())
+	})
+	var invalid *inputError
+	if errors.As(err, &invalid) {
+		if f.invalid == nil {
+			f.invalid = make(map[string]bool)
+		}
+		f.invalid[field] = true
+		return nil
+	}
+	return err
+}
+
+// fillField sets the field with the given Id to the values submitted for
+// the field with the given source Id.
+//
+// Composite widgets submit a parameter for each of their parts.
+func (f *Form) fillField(field Field, src, dst string,
+	values url.Values) error {
+	if fieldKind(field) == "file" {
+		if files := f.files[src]; len(files) > 0 {
+			return f.setFiles(dst, files)
+		}
+		return nil
+	}
+	composite, ok := field.Widget.(compositeWidget)
+	if !ok {
+		if submitted, ok := values[src]; ok && f.raw != nil {
+			f.raw[dst] = submitted
+		}
+		for _, value := range values[src] {
+			err := f.setNestedField(dst, field.Widget, field.filter(value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, part := range composite.parts() {
+		for _, value := range values[src+"."+part] {
+			err := f.setNestedField(dst+"."+part, field.Widget,
+				field.filter(value))
+			if err != nil {
+				return err
+			}
+		}
+		if f.invalid[dst+"."+part] {
+			f.invalid[dst] = true
+		}
 	}
 	return nil
 }
 
-// setNestedField searches for the given nested field in the given data
-func (f *Form) setNestedField(field string, value string) {
-	val, err := f.findNestedField(field, nil)
-	if err == nil {
-		f.findNestedField(field, stringToValue(value, val.Type()))
+// setFiles sets the field with the given Id to the given uploaded files.
+func (f *Form) setFiles(field string, files []*multipart.FileHeader) error {
+	_, err := f.findNestedField(field, func(target reflect.Type) (
+		interface{}, error) {
+		switch target {
+		case fileHeaderType:
+			return files[0], nil
+		case fileHeadersType:
+			return files, nil
+		}
+		return nil, fmt.Errorf("form: Could not bind files to %v", target)
+	})
+	return err
+}
+
+// filter applies the field's filters to the given value.
+func (f Field) filter(value string) string {
+	for _, filter := range f.Filters {
+		value = filter(value)
 	}
+	return value
 }
 
 // Fill fills the form data with the given values and validates the form.
 //
-// It panics if a field has been set up which is not present in the
-// data struct.
+// Fields which can't be bound to the data are ignored. Use TryFill to
+// detect them.
 //
-// Values that don't match a field will be ignored.
+// Values that don't match a field will be ignored unless the form is in
+// strict mode, see Form.Strict.
+//
+// The submitted values are kept and rendered instead of the data's values
+// for fields with errors, so that users can correct their input.
 //
 // Returns true iff the form validates.
 func (f *Form) Fill(values url.Values) bool {
-	for _, field := range f.Fields {
-		if paramValue, ok := values[field.Id]; ok {
-			fieldValue, err := f.getNestedField(field.Id)
-			if err != nil {
-				continue
-			}
-			fieldType := fieldValue.Type()
-			if fieldType.Kind() == reflect.Slice {
-				fieldType = fieldType.Elem()
-			}
-			for _, value := range paramValue {
-				f.setNestedField(field.Id, value)
+	valid, _ := f.TryFill(values)
+	return valid
+}
+
+// FillMultipart is like Fill but fills the form with the values of the given
+// multipart form, e.g. http.Request.MultipartForm, and binds its uploaded
+// files to the fields with a FileWidget.
+func (f *Form) FillMultipart(form *multipart.Form) bool {
+	valid, _ := f.TryFillMultipart(form)
+	return valid
+}
+
+// TryFillMultipart is like FillMultipart but returns errors like TryFill.
+func (f *Form) TryFillMultipart(form *multipart.Form) (bool, error) {
+	if form == nil {
+		return f.TryFill(url.Values{})
+	}
+	f.files = form.File
+	defer func() { f.files = nil }()
+	return f.TryFill(url.Values(form.Value))
+}
+
+// TryFill is like Fill but returns an error if a field can't be bound to
+// the data, e.g. because it's not present in the data or has an unsupported
+// type.
+//
+// The error does not depend on the given values. All other fields get
+// filled anyway.
+func (f *Form) TryFill(values url.Values) (bool, error) {
+	var firstErr error
+	f.errors, f.invalid = nil, nil
+	f.raw = make(map[string][]string)
+	paramsOk := true
+	if f.Strict != nil {
+		paramsOk = f.checkParams(values)
+	}
+	spamOk := true
+	if f.AntiSpam != nil {
+		var err error
+		if spamOk, err = f.checkSpam(values); err != nil {
+			firstErr = err
+		}
+	}
+	for _, field := range sortByCondition(f.Fields) {
+		if problem := f.bindingProblem(field, field.Id); problem != "" {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("form: %v", problem)
 			}
+			continue
+		}
+		if f.hiddenField(field) {
+			continue
 		}
+		err := f.fillField(field, field.Id, field.Id, values)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, formset := range f.Formsets {
+		if err := f.fillFormset(formset, values); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	valid, err := f.validate()
+	if err != nil && firstErr == nil {
+		firstErr = err
 	}
-	return f.validate()
+	return valid && paramsOk && spamOk && firstErr == nil, firstErr
 }
 
 // validate validates the currently present data.
 //
 // Resets any previous errors.
-// Returns true iff the data validates.
-func (f *Form) validate() bool {
+// Returns true iff the data validates and the first error of an
+// OptionProvider.
+func (f *Form) validate() (bool, error) {
+	var firstErr error
 	anyError := false
-	for _, field := range f.Fields {
-		value, err := f.getNestedField(field.Id)
-		if err != nil {
-			return false
+	fields := f.Fields
+	if len(f.Formsets) > 0 {
+		fields = append([]Field(nil), f.Fields...)
+		for _, formset := range f.Formsets {
+			if !f.validateFormset(formset) {
+				anyError = true
+			}
+			fields = append(fields, f.formsetFields(formset)...)
+		}
+	}
+	for _, field := range fields {
+		if f.hiddenField(field) {
+			continue
 		}
+		// Fields behind nil pointers or missing map entries have the zero
+		// value.
+		value, _ := f.getNestedField(field.Id)
+		if f.invalid[field.Id] {
+			msg := f.InvalidMsg
+			if msg == "" {
+				msg = "Invalid value."
+			}
+			if f.errors == nil {
+				f.errors = make(map[string][]string)
+			}
+			f.errors[field.Id] = []string{msg}
+			anyError = true
+			continue
+		}
+		var fieldValue interface{}
+		if value.IsValid() {
+			fieldValue = value.Interface()
+		}
+		var errors []string
 		if field.Validator != nil {
-			if errors := field.Validator(value.Interface()); errors != nil {
-				f.errors[field.Id] = errors
+			errors = field.Validator(fieldValue)
+		}
+		choiceErrors, err := f.validateChoice(field, fieldValue)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		errors = append(errors, choiceErrors...)
+		errors = append(errors, validateBounds(field, fieldValue)...)
+		if errors != nil {
+			if f.errors == nil {
+				f.errors = make(map[string][]string)
+			}
+			f.errors[field.Id] = errors
+			anyError = true
+		}
+	}
+	for _, validator := range f.Validators {
+		for field, errors := range validator(f.data) {
+			for _, err := range errors {
+				f.AddError(field, err)
 				anyError = true
 			}
 		}
 	}
-	return !anyError
+	return !anyError, firstErr
 }
 
 // Validator is a function which validates the given data and returns error
 // messages if the data does not validate.
 type Validator func(interface{}) []string
 
+// FormValidator is a function which validates the given data of a form as a
+// whole, e.g. to compare several fields. It returns error messages by field
+// Id if the data does not validate. Global errors use an empty Id.
+type FormValidator func(data interface{}) map[string][]string
+
 // And is a Validator that collects errors of all given validators.
 func And(vs ...Validator) Validator {
 	return func(value interface{}) []string {
@@ -456,7 +1054,12 @@ func And(vs ...Validator) Validator {
 // msg is set as validation error.
 func Required(msg string) Validator {
 	return func(value interface{}) []string {
-		if value == reflect.Zero(reflect.TypeOf(value)).Interface() {
+		v := reflect.ValueOf(value)
+		if !v.IsValid() || v.IsZero() {
+			return []string{msg}
+		}
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) &&
+			v.Len() == 0 {
 			return []string{msg}
 		}
 		return nil
@@ -469,7 +1072,11 @@ func Required(msg string) Validator {
 // the given error msg is returned.
 func Regex(exp, msg string) Validator {
 	return func(value interface{}) []string {
-		if matched, _ := regexp.MatchString(exp, value.(string)); !matched {
+		str, ok := value.(string)
+		if !ok {
+			str = fmt.Sprintf("%v", value)
+		}
+		if matched, _ := regexp.MatchString(exp, str); !matched {
 			return []string{msg}
 		}
 		return nil
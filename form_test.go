@@ -17,9 +17,12 @@
 package form
 
 import (
+	"bytes"
 	"html/template"
+	"mime/multipart"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -40,10 +43,12 @@ func TestRender(t *testing.T) {
 	data.Extra = make(map[string]interface{})
 	data.Extra["ExtraField"] = ""
 	form := NewForm(&data, []Field{
-		Field{"Title", "Your title", "", nil, nil},
-		Field{"Name", "Your name", "Your full name", Required("Req!"), nil},
-		Field{"Age", "Your age", "Years since your birth.", Required("Req!"), nil},
-		Field{"Extra.ExtraField", "Extra Field", "", nil, nil},
+		Field{Id: "Title", Label: "Your title"},
+		Field{Id: "Name", Label: "Your name", Help: "Your full name",
+			Validator: Required("Req!")},
+		Field{Id: "Age", Label: "Your age", Help: "Years since your birth.",
+			Validator: Required("Req!")},
+		Field{Id: "Extra.ExtraField", Label: "Extra Field"},
 	})
 	vals := url.Values{
 		"Title":            []string{""},
@@ -64,6 +69,9 @@ func TestRender(t *testing.T) {
 		{
 			Field: "Title",
 			Expected: FieldRenderData{
+				Id:       "Title",
+				Kind:     "text",
+				Value:    "",
 				Label:    "Your title",
 				LabelTag: `<label for="Title">Your title</label>`,
 				Help:     "",
@@ -72,6 +80,9 @@ func TestRender(t *testing.T) {
 		{
 			Field: "Name",
 			Expected: FieldRenderData{
+				Id:       "Name",
+				Kind:     "text",
+				Value:    "",
 				Label:    "Your name",
 				LabelTag: `<label for="Name">Your name</label>`,
 				Help:     "Your full name",
@@ -80,6 +91,9 @@ func TestRender(t *testing.T) {
 		{
 			Field: "AGE",
 			Expected: FieldRenderData{
+				Id:       "Age",
+				Kind:     "text",
+				Value:    14,
 				Label:    "Your age",
 				LabelTag: `<label for="Age">Your age</label>`,
 				Help:     "Years since your birth.",
@@ -88,6 +102,9 @@ func TestRender(t *testing.T) {
 		{
 			Field: "ExtraField",
 			Expected: FieldRenderData{
+				Id:       "Extra.ExtraField",
+				Kind:     "text",
+				Value:    "Hey!",
 				Label:    "Extra Field",
 				LabelTag: `<label for="Extra.ExtraField">Extra Field</label>`,
 				Help:     "",
@@ -113,9 +130,12 @@ func TestMapRender(t *testing.T) {
 		"Bar": "ee"}
 
 	form := NewForm(data, []Field{
-		Field{"Name", "Your name", "Your full name", Required("Req!"), nil},
-		Field{"Age", "Your age", "Years since your birth.", Required("Req!"), nil},
-		Field{"Foo.Bar", "Bar", "Some foo's bar.", Required("Req!"), nil},
+		Field{Id: "Name", Label: "Your name", Help: "Your full name",
+			Validator: Required("Req!")},
+		Field{Id: "Age", Label: "Your age", Help: "Years since your birth.",
+			Validator: Required("Req!")},
+		Field{Id: "Foo.Bar", Label: "Bar", Help: "Some foo's bar.",
+			Validator: Required("Req!")},
 	})
 	vals := url.Values{
 		"Name":    []string{""},
@@ -131,6 +151,9 @@ func TestMapRender(t *testing.T) {
 		{
 			Field: "Name",
 			Expected: FieldRenderData{
+				Id:       "Name",
+				Kind:     "text",
+				Value:    "",
 				Label:    "Your name",
 				LabelTag: `<label for="Name">Your name</label>`,
 				Help:     "Your full name",
@@ -139,6 +162,9 @@ func TestMapRender(t *testing.T) {
 		{
 			Field: "AGE",
 			Expected: FieldRenderData{
+				Id:       "Age",
+				Kind:     "text",
+				Value:    14,
 				Label:    "Your age",
 				LabelTag: `<label for="Age">Your age</label>`,
 				Help:     "Years since your birth.",
@@ -147,6 +173,9 @@ func TestMapRender(t *testing.T) {
 		{
 			Field: "Foo.Bar",
 			Expected: FieldRenderData{
+				Id:       "Foo.Bar",
+				Kind:     "text",
+				Value:    "Bla",
 				Label:    "Bar",
 				LabelTag: `<label for="Foo.Bar">Bar</label>`,
 				Help:     "Some foo's bar.",
@@ -167,8 +196,10 @@ func TestMapRender(t *testing.T) {
 func TestAddError(t *testing.T) {
 	data := TestData{}
 	form := NewForm(&data, []Field{
-		Field{"Name", "Your name", "Your full name", Required("Req!"), nil},
-		Field{"Age", "Your age", "Years since your birth.", Required("Req!"), nil}})
+		Field{Id: "Name", Label: "Your name", Help: "Your full name",
+			Validator: Required("Req!")},
+		Field{Id: "Age", Label: "Your age", Help: "Years since your birth.",
+			Validator: Required("Req!")}})
 	form.AddError("Name", "Foo")
 	form.AddError("", "Bar")
 	renderData := form.RenderData()
@@ -200,14 +231,15 @@ func TestEncTypeAttr(t *testing.T) {
 	}{
 		{
 			Form: NewForm(&data, []Field{
-				Field{"Name", "Your name", "Your full name", Required("Req!"),
-					nil},
-				Field{"File", "File Dummy", "", nil, nil}}),
+				Field{Id: "Name", Label: "Your name", Help: "Your full name",
+					Validator: Required("Req!")},
+				Field{Id: "File", Label: "File Dummy"}}),
 			EncType: ""},
 		{
 			Form: NewForm(&data, []Field{
-				Field{"Name", "Your name", "Your full name", Required("Req!"), nil},
-				Field{"File", "File!", "", nil, new(FileWidget)}}),
+				Field{Id: "Name", Label: "Your name", Help: "Your full name",
+					Validator: Required("Req!")},
+				Field{Id: "File", Label: "File!", Widget: new(FileWidget)}}),
 			EncType: `enctype="multipart/form-data"`}}
 
 	for i, v := range fieldTests {
@@ -225,9 +257,11 @@ func TestFill(t *testing.T) {
 	data.Extra = make(map[string]interface{}, 0)
 	data.Extra["Number"] = new(int)
 	form := NewForm(&data, []Field{
-		Field{"Name", "Your name", "Your full name", Required("Req!"), nil},
-		Field{"Age", "Your age", "Years since your birth.", Required("Req!"), nil},
-		Field{"Extra.Number", "Number", "", nil, nil},
+		Field{Id: "Name", Label: "Your name", Help: "Your full name",
+			Validator: Required("Req!")},
+		Field{Id: "Age", Label: "Your age", Help: "Years since your birth.",
+			Validator: Required("Req!")},
+		Field{Id: "Extra.Number", Label: "Number"},
 	})
 	vals := url.Values{
 		"Name":         []string{"Foo"},
@@ -307,9 +341,9 @@ func TestAnd(t *testing.T) {
 }
 
 func TestSelectWidget(t *testing.T) {
-	widget := SelectWidget{[]Option{
-		Option{"foo", "The Foo!"},
-		Option{"bar", "The Bar!"}}}
+	widget := SelectWidget{Options: []Option{
+		Option{Value: "foo", Text: "The Foo!"},
+		Option{Value: "bar", Text: "The Bar!"}}}
 	tests := []struct {
 		Name, Value, Expected string
 	}{
@@ -366,11 +400,19 @@ func TestPasswordWidget(t *testing.T) {
 		t.Errorf(`PasswordWidget.HTML("Foo", "") = "%v", should be "%v".`,
 			ret, expected)
 	}
+	ret = PasswordWidget{Autocomplete: "new-password"}.HTML("foo", "secret")
+	expected = `<input id="foo" type="password" name="foo" ` +
+		`autocomplete="new-password"/>`
+	if string(ret) != expected {
+		t.Errorf(`PasswordWidget.HTML("Foo", "secret") = "%v", should be "%v".`,
+			ret, expected)
+	}
 }
 
 func testWidget(t *testing.T, widget Widget, data interface{}, input,
 	nilInput string, value interface{}, urlValue string) {
-	form := NewForm(data, []Field{Field{"ID", "T", "H", nil, widget}})
+	form := NewForm(data, []Field{Field{Id: "ID", Label: "T", Help: "H",
+		Widget: widget}})
 	vals := url.Values{"ID": []string{urlValue}}
 	renderData := form.RenderData()
 	if renderData.Fields[0].Input != template.HTML(nilInput) {
@@ -395,8 +437,8 @@ type TestDateTimeWidgetData struct {
 
 func TestDateTimeWidget(t *testing.T) {
 	data := TestDateTimeWidgetData{}
-	input := `<input id="ID" type="datetime" name="ID" value="2008-09-08T22:47:31-07:00"/>`
-	nilInput := `<input id="ID" type="datetime" name="ID" value=""/>`
+	input := `<input id="ID" type="datetime-local" name="ID" value="2008-09-09T05:47:31"/>`
+	nilInput := `<input id="ID" type="datetime-local" name="ID" value=""/>`
 	value, err := time.Parse(time.RFC3339, "2008-09-08T22:47:31-07:00")
 	if err != nil {
 		t.Fatal(err)
@@ -405,7 +447,6 @@ func TestDateTimeWidget(t *testing.T) {
 		"2008-09-08T22:47:31-07:00")
 }
 
-/*
 func TestDateWidget(t *testing.T) {
 	data := TestDateTimeWidgetData{}
 	input := `<input id="ID" type="date" name="ID" value="2008-09-08"/>`
@@ -427,4 +468,549 @@ func TestTimeWidget(t *testing.T) {
 	}
 	testWidget(t, new(TimeWidget), &data, input, nilInput, value, "22:47:31")
 }
-*/
+
+type TestTimeData struct {
+	At    time.Time
+	AtPtr *time.Time
+}
+
+func TestTimeFields(t *testing.T) {
+	date := time.Date(2008, 9, 8, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Value    string
+		Valid    bool
+		Expected time.Time
+	}{
+		{"2008-09-08T22:47:31Z", true, date.Add(22*time.Hour + 47*time.Minute +
+			31*time.Second)},
+		{"2008-09-08T22:47", true, date.Add(22*time.Hour + 47*time.Minute)},
+		{"2008-09-08", true, date},
+		{"22:47:31", true, time.Date(0, 1, 1, 22, 47, 31, 0, time.UTC)},
+		{"22:47", true, time.Date(0, 1, 1, 22, 47, 0, 0, time.UTC)},
+		{"2026-10", true, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-W42", true, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)},
+		{"2026-W53", true, time.Date(2026, 12, 28, 0, 0, 0, 0, time.UTC)},
+		{"2021-W01", true, time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"", true, time.Time{}},
+		{"2025-W53", false, time.Time{}},
+		{"2026-W00", false, time.Time{}},
+		{"2008-13-08", false, time.Time{}},
+		{"yesterday", false, time.Time{}},
+	}
+	for i, test := range tests {
+		data := TestTimeData{}
+		form := NewForm(&data, []Field{
+			Field{Id: "At", Widget: new(DateWidget)},
+			Field{Id: "AtPtr", Widget: new(DateTimeWidget)}})
+		form.InvalidMsg = "Invalid!"
+		valid, err := form.TryFill(url.Values{"At": {test.Value},
+			"AtPtr": {test.Value}})
+		if err != nil || valid != test.Valid {
+			t.Errorf("Test %v: TryFill(%q) returned %v, %v, should be %v", i,
+				test.Value, valid, err, test.Valid)
+		}
+		if !data.At.Equal(test.Expected) {
+			t.Errorf("Test %v: At is %v, should be %v", i, data.At,
+				test.Expected)
+		}
+		if test.Expected.IsZero() {
+			if data.AtPtr != nil {
+				t.Errorf("Test %v: AtPtr should be nil, is %v", i, data.AtPtr)
+			}
+		} else if data.AtPtr == nil || !data.AtPtr.Equal(test.Expected) {
+			t.Errorf("Test %v: AtPtr is %v, should be %v", i, data.AtPtr,
+				test.Expected)
+		}
+		for _, field := range form.RenderData().Fields {
+			if !test.Valid && (len(field.Errors) != 1 ||
+				field.Errors[0] != "Invalid!") {
+				t.Errorf("Test %v: Errors of %v are %v", i, field.Id,
+					field.Errors)
+			}
+		}
+	}
+}
+
+func TestDurationFields(t *testing.T) {
+	tests := []struct {
+		Value    string
+		Valid    bool
+		Expected time.Duration
+	}{
+		{"1h30m", true, 90 * time.Minute},
+		{"-1.5s", true, -1500 * time.Millisecond},
+		{"0", true, 0},
+		{"", true, 0},
+		{"90", false, 0},
+		{"soon", false, 0},
+	}
+	for i, test := range tests {
+		data := struct {
+			Timeout time.Duration
+			Delay   *time.Duration
+		}{}
+		form := NewForm(&data, []Field{
+			Field{Id: "Timeout", Widget: new(DurationWidget)},
+			Field{Id: "Delay", Widget: new(DurationWidget)}})
+		valid, err := form.TryFill(url.Values{"Timeout": {test.Value},
+			"Delay": {test.Value}})
+		if err != nil || valid != test.Valid {
+			t.Errorf("Test %v: TryFill(%q) returned %v, %v, should be %v", i,
+				test.Value, valid, err, test.Valid)
+		}
+		if data.Timeout != test.Expected {
+			t.Errorf("Test %v: Timeout is %v, should be %v", i, data.Timeout,
+				test.Expected)
+		}
+		if test.Valid && test.Value != "" &&
+			(data.Delay == nil || *data.Delay != test.Expected) {
+			t.Errorf("Test %v: Delay is %v, should be %v", i, data.Delay,
+				test.Expected)
+		}
+	}
+}
+
+func TestDateTimeLocation(t *testing.T) {
+	tests := []struct {
+		Location string
+		UTC      string
+		Local    string
+	}{
+		// Before and after the start of daylight saving time.
+		{"Europe/Berlin", "2021-03-28T00:59:00Z", "2021-03-28T01:59"},
+		{"Europe/Berlin", "2021-03-28T01:00:00Z", "2021-03-28T03:00"},
+		// Before and after the end of daylight saving time, leaving out
+		// the ambiguous hour.
+		{"Europe/Berlin", "2021-10-30T23:59:00Z", "2021-10-31T01:59"},
+		{"Europe/Berlin", "2021-10-31T02:00:00Z", "2021-10-31T03:00"},
+		{"America/New_York", "2021-03-14T06:59:30Z", "2021-03-14T01:59:30"},
+		{"America/New_York", "2021-03-14T07:00:00Z", "2021-03-14T03:00"},
+		{"America/New_York", "2021-11-07T04:59:00Z", "2021-11-07T00:59"},
+		{"America/New_York", "2021-11-07T07:00:00Z", "2021-11-07T02:00"},
+		{"UTC", "2021-11-07T07:00:00Z", "2021-11-07T07:00"},
+	}
+	for i, test := range tests {
+		loc, err := time.LoadLocation(test.Location)
+		if err != nil {
+			t.Skipf("Time zone data not available: %v", err)
+		}
+		at, err := time.Parse(time.RFC3339, test.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := TestTimeData{At: at, AtPtr: &at}
+		form := NewForm(&data, []Field{
+			Field{Id: "At", Widget: new(DateTimeWidget)},
+			Field{Id: "AtPtr", Widget: new(DateTimeWidget)}})
+		form.Location = loc
+		for _, field := range form.RenderData().Fields {
+			if !strings.Contains(string(field.Input),
+				`value="`+test.Local+`"`) {
+				t.Errorf("Test %v: Input of %v is %v, should contain %v", i,
+					field.Id, field.Input, test.Local)
+			}
+		}
+		data = TestTimeData{}
+		if !form.Fill(url.Values{"At": {test.Local}, "AtPtr": {test.Local}}) {
+			t.Errorf("Test %v: Fill(%q) failed", i, test.Local)
+		}
+		if !data.At.Equal(at) || data.AtPtr == nil || !data.AtPtr.Equal(at) {
+			t.Errorf("Test %v: Fill(%q) set %v and %v, should be %v", i,
+				test.Local, data.At, data.AtPtr, at)
+		}
+	}
+}
+
+func TestFieldsets(t *testing.T) {
+	data := TestData{Name: "Foo"}
+	form := NewForm(&data, []Field{
+		Field{Id: "Title", Label: "Your title"},
+		Field{Id: "Name", Label: "Your name"},
+		Field{Id: "Age", Label: "Your age"}})
+	form.Fieldsets = []Fieldset{
+		Fieldset{Legend: "Person", Description: "About you",
+			Fields: []string{"Name", "Unknown"},
+			Fieldsets: []Fieldset{
+				Fieldset{Legend: "Details", Fields: []string{"Age", "Title"}}}}}
+	renderData := form.RenderData()
+	if len(renderData.Fields) != 3 {
+		t.Errorf("RenderData.Fields should contain all 3 fields, got %v",
+			len(renderData.Fields))
+	}
+	if len(renderData.Fieldsets) != 1 {
+		t.Fatalf("RenderData.Fieldsets should contain 1 fieldset, got %v",
+			len(renderData.Fieldsets))
+	}
+	person := renderData.Fieldsets[0]
+	if person.Legend != "Person" || person.Description != "About you" {
+		t.Errorf("Fieldset has legend %q and description %q", person.Legend,
+			person.Description)
+	}
+	if len(person.Fields) != 1 || person.Fields[0].Id != "Name" ||
+		person.Fields[0].Value != "Foo" {
+		t.Errorf("Fieldset should contain field Name only, got %v",
+			person.Fields)
+	}
+	if len(person.Fieldsets) != 1 || len(person.Fieldsets[0].Fields) != 2 ||
+		person.Fieldsets[0].Fields[0].Id != "Age" ||
+		person.Fieldsets[0].Fields[1].Id != "Title" {
+		t.Errorf("Nested fieldset should contain Age and Title, got %v",
+			person.Fieldsets)
+	}
+	form.Fieldsets = nil
+	if renderData := form.RenderData(); renderData.Fieldsets != nil {
+		t.Errorf("RenderData.Fieldsets should be nil without fieldsets, got %v",
+			renderData.Fieldsets)
+	}
+}
+
+type TestItem struct {
+	Name string
+	Tags []string
+}
+
+type TestIndexedData struct {
+	Items  []TestItem
+	Matrix [2][2]int
+	Lists  map[string][]int
+}
+
+func TestIndexedFields(t *testing.T) {
+	data := TestIndexedData{
+		Items: []TestItem{TestItem{Name: "First"}},
+		Lists: map[string][]int{"Foo": []int{1}}}
+	form := NewForm(&data, []Field{
+		Field{Id: "Items.0.Name"},
+		Field{Id: "Items[2].Name"},
+		Field{Id: "Items[2].Tags[1]"},
+		Field{Id: "Matrix[1][0]"},
+		Field{Id: "Matrix.0.5"},
+		Field{Id: "Lists.Foo.2"},
+		Field{Id: "Items.5000.Name"},
+		Field{Id: "Items.-1.Name"},
+	})
+	vals := url.Values{
+		"Items.0.Name":     []string{"One"},
+		"Items[2].Name":    []string{"Three"},
+		"Items[2].Tags[1]": []string{"b"},
+		"Matrix[1][0]":     []string{"4"},
+		"Matrix.0.5":       []string{"7"},
+		"Lists.Foo.2":      []string{"3"},
+		"Items.5000.Name":  []string{"Too far"},
+		"Items.-1.Name":    []string{"Negative"},
+	}
+	form.Fill(vals)
+	expected := TestIndexedData{
+		Items: []TestItem{
+			TestItem{Name: "One"},
+			TestItem{},
+			TestItem{Name: "Three", Tags: []string{"", "b"}}},
+		Matrix: [2][2]int{{0, 0}, {4, 0}},
+		Lists:  map[string][]int{"Foo": []int{1, 0, 3}}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Filled data is\n%v\nshould be\n%v", data, expected)
+	}
+	renderData := form.RenderData()
+	if renderData.Fields[2].Value != "b" {
+		t.Errorf(`Value of "Items[2].Tags[1]" is %q, should be "b"`,
+			renderData.Fields[2].Value)
+	}
+	if renderData.Fields[6].Value != "" {
+		t.Errorf(`Value of "Items.5000.Name" is %q, should be empty`,
+			renderData.Fields[6].Value)
+	}
+}
+
+type TestCity struct {
+	Name string
+	Zip  *int
+}
+
+type TestAllocAddress struct {
+	City   *TestCity
+	Street string
+}
+
+type TestAllocData struct {
+	Address *TestAllocAddress
+	Cities  map[string]*TestCity
+	Extra   map[string]interface{}
+	Any     interface{}
+}
+
+func TestFillAllocates(t *testing.T) {
+	data := TestAllocData{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Address.Street"},
+		Field{Id: "Address.City.Name"},
+		Field{Id: "Address.City.Zip"},
+		Field{Id: "Cities.Berlin.Name"},
+		Field{Id: "Extra.Foo.Bar"},
+		Field{Id: "Any.Baz"},
+	})
+	form.Fill(url.Values{
+		"Address.Street":     []string{"Main Street"},
+		"Address.City.Name":  []string{"Springfield"},
+		"Address.City.Zip":   []string{"12345"},
+		"Cities.Berlin.Name": []string{"Berlin"},
+		"Extra.Foo.Bar":      []string{"Bar!"},
+		"Any.Baz":            []string{"Baz!"},
+	})
+	zip := 12345
+	expected := TestAllocData{
+		Address: &TestAllocAddress{
+			Street: "Main Street",
+			City:   &TestCity{Name: "Springfield", Zip: &zip}},
+		Cities: map[string]*TestCity{"Berlin": &TestCity{Name: "Berlin"}},
+		Extra: map[string]interface{}{
+			"Foo": map[string]interface{}{"Bar": "Bar!"}},
+		Any: map[string]interface{}{"Baz": "Baz!"}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Filled data is\n%#v\nshould be\n%#v", data, expected)
+	}
+
+	data = TestAllocData{}
+	form.Fill(url.Values{})
+	if !reflect.DeepEqual(data, TestAllocData{}) {
+		t.Errorf("Fill without values should not allocate, data is %#v", data)
+	}
+	renderData := form.RenderData()
+	if renderData.Fields[1].Value != "" {
+		t.Errorf(`Value of "Address.City.Name" is %q, should be empty`,
+			renderData.Fields[1].Value)
+	}
+}
+
+func TestValidateUnallocated(t *testing.T) {
+	data := struct {
+		Address *TestAllocAddress
+		Name    string
+	}{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Address.Street"},
+		Field{Id: "Name", Validator: Required("Req!")}})
+	if valid, err := form.TryFill(url.Values{"Name": {"x"}}); !valid ||
+		err != nil || data.Address != nil {
+		t.Errorf("TryFill returned %v, %v, Address %v, should succeed "+
+			"without allocating", valid, err, data.Address)
+	}
+	data.Name = ""
+	if form.Fill(url.Values{}) ||
+		!reflect.DeepEqual(form.errors, map[string][]string{"Name": {"Req!"}}) {
+		t.Errorf("Fill should fail with errors for Name, got %v", form.errors)
+	}
+	form.Fields[0].Validator = Required("Req!")
+	if form.Fill(url.Values{"Name": {"x"}}) || !reflect.DeepEqual(form.errors,
+		map[string][]string{"Address.Street": {"Req!"}}) {
+		t.Errorf("Fill should fail with errors for Address.Street, got %v",
+			form.errors)
+	}
+}
+
+type TestName string
+
+type TestRobustData struct {
+	Name    TestName
+	Age     *int
+	Complex complex128
+	Tags    []string
+	private string
+	Extra   map[string]interface{}
+	Items   []TestItem
+}
+
+func TestTryNewForm(t *testing.T) {
+	var nilData *TestData
+	for i, data := range []interface{}{nil, TestData{}, "foo", nilData,
+		map[string]string(nil)} {
+		form, err := TryNewForm(data, nil)
+		if err == nil || form != nil {
+			t.Errorf("Test %v: TryNewForm(%#v) should fail", i, data)
+		}
+	}
+	if _, err := TryNewForm(&TestData{}, nil); err != nil {
+		t.Errorf("TryNewForm failed: %v", err)
+	}
+}
+
+func TestTryFill(t *testing.T) {
+	data := TestRobustData{Extra: map[string]interface{}{"Nil": nil}}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Validator: Required("Req!")},
+		Field{Id: "Age", Validator: Required("Req!")},
+		Field{Id: "Extra.Nil", Validator: Required("Req!")}})
+	valid, err := form.TryFill(url.Values{
+		"Name":      []string{"Foo"},
+		"Age":       []string{"12"},
+		"Extra.Nil": []string{"Bar"}})
+	if err != nil || !valid {
+		t.Errorf("TryFill returned %v, %v, should be true, nil", valid, err)
+	}
+	if data.Name != "Foo" || data.Age == nil || *data.Age != 12 ||
+		data.Extra["Nil"] != "Bar" {
+		t.Errorf("Filled data is %#v", data)
+	}
+	for _, field := range []Field{
+		Field{Id: "Complex"},
+		Field{Id: "Tags"},
+		Field{Id: "Unknown"},
+		Field{Id: "private"}} {
+		data := TestRobustData{}
+		form := NewForm(&data, []Field{Field{Id: "Name"}, field})
+		valid, err := form.TryFill(url.Values{"Name": []string{"Foo"}})
+		if err == nil || valid {
+			t.Errorf("TryFill for field %q returned %v, %v, should fail",
+				field.Id, valid, err)
+		}
+		if data.Name != "Foo" {
+			t.Errorf("TryFill for field %q did not fill other fields", field.Id)
+		}
+		if form.Fill(url.Values{field.Id: []string{"1"}}) {
+			t.Errorf("Fill for field %q should return false", field.Id)
+		}
+	}
+}
+
+func TestRequiredNilAndUncomparable(t *testing.T) {
+	validator := Required("Req!")
+	for _, value := range []interface{}{nil, []string(nil), map[string]int{},
+		(*int)(nil)} {
+		if validator(value) == nil {
+			t.Errorf("Required(%#v) should fail", value)
+		}
+	}
+	for _, value := range []interface{}{[]string{"a"}, new(int)} {
+		if validator(value) != nil {
+			t.Errorf("Required(%#v) should succeed", value)
+		}
+	}
+}
+
+func TestFillMultipart(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("Name", "Foo")
+	for _, name := range []string{"Avatar", "Photos", "Photos"} {
+		part, err := writer.CreateFormFile(name, name+".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("png"))
+	}
+	writer.Close()
+	multipartForm, err := multipart.NewReader(&body,
+		writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := struct {
+		Name   string
+		Avatar *multipart.FileHeader
+		Photos []*multipart.FileHeader
+		Other  *multipart.FileHeader
+	}{}
+	form := MustNewForm(&data, []Field{Field{Id: "Name"},
+		Field{Id: "Avatar", Widget: new(FileWidget)},
+		Field{Id: "Photos", Widget: new(FileWidget)},
+		Field{Id: "Other", Validator: Required("Req!"),
+			Widget: new(FileWidget)}})
+	if form.FillMultipart(multipartForm) {
+		t.Errorf("FillMultipart should fail without required file")
+	}
+	if data.Name != "Foo" || data.Avatar == nil ||
+		data.Avatar.Filename != "Avatar.png" || len(data.Photos) != 2 {
+		t.Errorf("FillMultipart did not bind the files, got %+v", data)
+	}
+	if !reflect.DeepEqual(form.errors, map[string][]string{
+		"Other": {"Req!"}}) {
+		t.Errorf("Errors are %v", form.errors)
+	}
+}
+
+func TestStickyInput(t *testing.T) {
+	data := struct {
+		Name  string
+		Age   int
+		Count uint8
+		Email string
+	}{Age: 7}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Filters: []Filter{TrimSpace}},
+		Field{Id: "Age"},
+		Field{Id: "Count"},
+		Field{Id: "Email", Validator: Regex("@", "Invalid email!")}})
+	if form.Fill(url.Values{"Name": {" Foo "}, "Age": {"12abc"},
+		"Count": {"300"}, "Email": {"foo<at>example.com"}}) {
+		t.Fatalf("Fill should fail")
+	}
+	if data.Age != 7 {
+		t.Errorf("Age = %v, should not be changed by invalid input", data.Age)
+	}
+	renderData := form.RenderData()
+	expected := []string{
+		`<input id="Name" type="text" name="Name" value="Foo"/>`,
+		`<input id="Age" type="text" name="Age" value="12abc"/>`,
+		`<input id="Count" type="text" name="Count" value="300"/>`,
+		`<input id="Email" type="text" name="Email" ` +
+			`value="foo&lt;at&gt;example.com"/>`}
+	for i, field := range renderData.Fields {
+		if string(field.Input) != expected[i] {
+			t.Errorf("Input of %v is %v, should be %v", field.Id, field.Input,
+				expected[i])
+		}
+	}
+	if !form.Fill(url.Values{"Age": {""}, "Count": {"010"},
+		"Email": {"a@b"}}) {
+		t.Errorf("Fill failed: %v", form.errors)
+	}
+	if data.Age != 0 || data.Count != 10 {
+		t.Errorf("Age and Count are %v and %v, should be 0 and 10", data.Age,
+			data.Count)
+	}
+	if input := form.RenderData().Fields[2].Input; !strings.Contains(
+		string(input), `value="10"`) {
+		t.Errorf("Input of Count is %v, should render the bound value", input)
+	}
+	// Numbers are decimal.
+	if !form.Fill(url.Values{"Age": {"08"}, "Count": {"09"}}) ||
+		data.Age != 8 || data.Count != 9 {
+		t.Errorf("Fill returned %+v, errors: %v", data, form.errors)
+	}
+	for _, value := range []string{"0x10", "1_000", "0b1", "0o7"} {
+		if form.Fill(url.Values{"Age": {value}}) || data.Age != 8 {
+			t.Errorf("Fill should reject %q, Age is %v", value, data.Age)
+		}
+		if form.Fill(url.Values{"Count": {value}}) || data.Count != 9 {
+			t.Errorf("Fill should reject %q, Count is %v", value, data.Count)
+		}
+	}
+}
+
+func FuzzFill(f *testing.F) {
+	f.Add("Name", "Foo")
+	f.Add("Age", "-1")
+	f.Add("Items.0.Name", "x")
+	f.Add("Items.count", "99999999999")
+	f.Add("Items.3.order", "-5")
+	f.Add("Extra.Nil", "")
+	f.Fuzz(func(t *testing.T, key, value string) {
+		data := TestRobustData{Extra: map[string]interface{}{"Nil": nil,
+			"Map": map[string]int{}}}
+		form := NewForm(&data, []Field{
+			Field{Id: "Name",
+				Validator: And(Required("Req!"), Regex("^a", "a!"))},
+			Field{Id: "Age", Validator: Required("Req!")},
+			Field{Id: "Extra.Nil", Validator: Required("Req!")},
+			Field{Id: "Extra.Map.Foo"},
+			Field{Id: "Items[1].Tags.2"},
+			Field{Id: key, Validator: Required("Req!"),
+				Widget: new(SelectWidget)}})
+		form.Formsets = []Formset{Formset{Id: "Items", DeleteLabel: "X",
+			Fields: []Field{Field{Id: "Name", Validator: Required("Req!")}}}}
+		form.Strict = &StrictMode{}
+		form.TryFill(url.Values{key: []string{value, value}})
+		form.RenderData()
+		form.TryFill(url.Values{key: {}, "Items.count": {}})
+		form.RenderData()
+	})
+}
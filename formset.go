@@ -0,0 +1,243 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Formset is a repeating sub-form bound to a slice of structs.
+//
+// Each element of the slice is edited by one row of the formset. The Ids of
+// the rows' fields are prefixed with the formset's Id and the row's index,
+// e.g. "Addresses.0.Street".
+//
+// Rows are managed with the following submitted parameters:
+//
+//	Addresses.count     the number of submitted rows
+//	Addresses.0.delete  removes the row if true
+//	Addresses.0.order   the new position of the row
+//
+// To add rows, clients increase the count and submit the new rows' fields.
+type Formset struct {
+	// Id is the Id of the slice in the form's data, e.g. "Addresses".
+	Id, Label, Help string
+	// Fields contains the fields of each row. Their Ids are relative to the
+	// row, e.g. "Street".
+	Fields []Field
+	// Min and Max limit the number of rows. Max is ignored if zero.
+	Min, Max int
+	// MinMsg and MaxMsg are the validation errors if the number of rows
+	// exceeds the limits.
+	MinMsg, MaxMsg string
+	// DeleteLabel is the label of the checkbox to delete a row. Rows can't be
+	// deleted if it's empty.
+	DeleteLabel string
+}
+
+// FormsetRenderData contains the data needed for formset rendering.
+type FormsetRenderData struct {
+	Id, Label, Help string
+	// Count is the hidden input holding the number of rows.
+	Count template.HTML
+	// Rows contains the formset's rows.
+	Rows []FormsetRowRenderData
+	// Errors contains any validation errors of the formset itself.
+	Errors []string
+	// DeleteLabel is the label of the rows' Delete checkboxes.
+	DeleteLabel string
+}
+
+// FormsetRowRenderData contains the data needed for rendering a row of a
+// formset.
+type FormsetRowRenderData struct {
+	Index  int
+	Fields []FieldRenderData
+	// Delete is the checkbox to remove the row. It's empty if rows can't be
+	// deleted.
+	Delete template.HTML
+	// Order is the hidden input holding the row's position.
+	Order template.HTML
+}
+
+// rowPrefix returns the prefix of the Ids of the row with the given index.
+func (s Formset) rowPrefix(index int) string {
+	return fmt.Sprintf("%v.%v.", s.Id, index)
+}
+
+// formsetRows returns the slice bound to the given formset.
+func (f *Form) formsetRows(formset Formset) (reflect.Value, error) {
+	rows, err := f.getNestedField(formset.Id)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if rows.Kind() != reflect.Slice {
+		return reflect.Value{},
+			fmt.Errorf("form: Formset %q is not bound to a slice", formset.Id)
+	}
+	return rows, nil
+}
+
+// formsetFields returns the fields of all rows of the given formset.
+func (f *Form) formsetFields(formset Formset) []Field {
+	rows, err := f.formsetRows(formset)
+	if err != nil {
+		return nil
+	}
+	fields := make([]Field, 0, rows.Len()*len(formset.Fields))
+	for i := 0; i < rows.Len(); i++ {
+		for _, field := range formset.Fields {
+			fields = append(fields, prefixField(field, formset.rowPrefix(i)))
+		}
+	}
+	return fields
+}
+
+// fillFormset rebuilds the slice bound to the given formset from the given
+// values, applying deletion and reordering of rows.
+//
+// Returns an error if the formset or one of its fields can't be bound.
+func (f *Form) fillFormset(formset Formset, values url.Values) error {
+	rows, err := f.formsetRows(formset)
+	if err != nil {
+		return err
+	}
+	count := rows.Len()
+	param := values.Get(formset.Id + ".count")
+	if n, err := strconv.Atoi(param); err == nil && n >= 0 {
+		count = n
+	}
+	if count > maxSliceLen {
+		count = maxSliceLen
+	}
+	type row struct {
+		index, order int
+	}
+	kept := make([]row, 0, count)
+	for i := 0; i < count; i++ {
+		prefix := formset.rowPrefix(i)
+		if formset.DeleteLabel != "" {
+			if deleted, _ := strconv.ParseBool(values.Get(prefix + "delete")); deleted {
+				continue
+			}
+		}
+		order, err := strconv.Atoi(values.Get(prefix + "order"))
+		if err != nil {
+			order = i
+		}
+		kept = append(kept, row{i, order})
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].order < kept[j].order
+	})
+	newRows := reflect.MakeSlice(rows.Type(), len(kept), len(kept))
+	for i, row := range kept {
+		if row.index < rows.Len() {
+			newRows.Index(i).Set(rows.Index(row.index))
+		}
+	}
+	_, err = f.findNestedField(formset.Id, func(reflect.Type) (
+		interface{}, error) {
+		return newRows.Interface(), nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, field := range formset.Fields {
+		if problem := f.bindingProblem(field,
+			formset.rowPrefix(0)+field.Id); problem != "" {
+			return fmt.Errorf("form: %v", problem)
+		}
+	}
+	fields := sortByCondition(formset.Fields)
+	for i, row := range kept {
+		for _, field := range fields {
+			if f.hiddenField(prefixField(field, formset.rowPrefix(i))) {
+				continue
+			}
+			err := f.fillField(field, formset.rowPrefix(row.index)+field.Id,
+				formset.rowPrefix(i)+field.Id, values)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateFormset checks the number of rows of the given formset.
+//
+// Returns true iff the number of rows is within the formset's limits.
+func (f *Form) validateFormset(formset Formset) bool {
+	rows, err := f.formsetRows(formset)
+	if err != nil {
+		return false
+	}
+	if rows.Len() < formset.Min {
+		f.AddError(formset.Id, formset.MinMsg)
+		return false
+	}
+	if formset.Max > 0 && rows.Len() > formset.Max {
+		f.AddError(formset.Id, formset.MaxMsg)
+		return false
+	}
+	return true
+}
+
+// formsetRenderData returns the render data of the given formset.
+//
+// Returns the first error of an OptionProvider.
+func (f Form) formsetRenderData(formset Formset) (FormsetRenderData, error) {
+	var firstErr error
+	data := FormsetRenderData{
+		Id:          formset.Id,
+		Label:       formset.Label,
+		Help:        formset.Help,
+		Errors:      f.errors[formset.Id],
+		DeleteLabel: formset.DeleteLabel}
+	count := 0
+	if rows, err := f.formsetRows(formset); err == nil {
+		count = rows.Len()
+	}
+	data.Count = new(HiddenWidget).HTML(formset.Id+".count", count)
+	data.Rows = make([]FormsetRowRenderData, 0, count)
+	for i := 0; i < count; i++ {
+		prefix := formset.rowPrefix(i)
+		row := FormsetRowRenderData{
+			Index:  i,
+			Fields: make([]FieldRenderData, 0, len(formset.Fields)),
+			Order:  new(HiddenWidget).HTML(prefix+"order", i)}
+		if formset.DeleteLabel != "" {
+			row.Delete = renderWidget("formset-delete",
+				WidgetData{Id: prefix + "delete"})
+		}
+		for _, field := range formset.Fields {
+			fieldData, err := f.fieldRenderData(prefixField(field, prefix))
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			row.Fields = append(row.Fields, fieldData)
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return data, firstErr
+}
@@ -0,0 +1,169 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type TestAddress struct {
+	Street string
+	Number int
+	Note   string
+}
+
+type TestFormsetData struct {
+	Name      string
+	Addresses []TestAddress
+}
+
+func testFormset() Formset {
+	return Formset{
+		Id:    "Addresses",
+		Label: "Addresses",
+		Fields: []Field{
+			Field{Id: "Street", Label: "Street", Validator: Required("Req!")},
+			Field{Id: "Number", Label: "Number"}},
+		Min: 1, Max: 3, MinMsg: "Too few!", MaxMsg: "Too many!",
+		DeleteLabel: "Delete"}
+}
+
+func TestFormsetFill(t *testing.T) {
+	data := TestFormsetData{Addresses: []TestAddress{
+		TestAddress{"First", 1, "keep me"},
+		TestAddress{"Second", 2, "and me"}}}
+	form := NewForm(&data, []Field{Field{Id: "Name", Label: "Name"}})
+	form.Formsets = []Formset{testFormset()}
+	vals := url.Values{
+		"Name":               []string{"Foo"},
+		"Addresses.count":    []string{"3"},
+		"Addresses.0.Street": []string{"First"},
+		"Addresses.0.delete": []string{"true"},
+		"Addresses.1.Street": []string{"Second!"},
+		"Addresses.1.order":  []string{"5"},
+		"Addresses.2.Street": []string{"Third"},
+		"Addresses.2.Number": []string{"3"},
+		"Addresses.2.order":  []string{"1"}}
+	if !form.Fill(vals) {
+		t.Errorf("Fill should succeed, got errors %v", form.errors)
+	}
+	expected := []TestAddress{
+		TestAddress{"Third", 3, ""},
+		TestAddress{"Second!", 2, "and me"}}
+	if !reflect.DeepEqual(data.Addresses, expected) {
+		t.Errorf("Addresses are %v, should be %v", data.Addresses, expected)
+	}
+	if data.Name != "Foo" {
+		t.Errorf("Name is %q, should be %q", data.Name, "Foo")
+	}
+}
+
+func TestFormsetValidation(t *testing.T) {
+	tests := []struct {
+		Values        url.Values
+		Valid         bool
+		FormsetErrors []string
+		RowErrors     map[string][]string
+	}{
+		{url.Values{"Addresses.count": []string{"0"}}, false,
+			[]string{"Too few!"}, nil},
+		{url.Values{"Addresses.count": []string{"4"},
+			"Addresses.0.Street": []string{"a"},
+			"Addresses.1.Street": []string{"b"},
+			"Addresses.2.Street": []string{"c"},
+			"Addresses.3.Street": []string{"d"}}, false,
+			[]string{"Too many!"}, nil},
+		{url.Values{"Addresses.count": []string{"2"},
+			"Addresses.0.Street": []string{"a"}}, false,
+			nil, map[string][]string{"Addresses.1.Street": []string{"Req!"}}},
+		{url.Values{"Addresses.count": []string{"1000000000"}}, false,
+			[]string{"Too many!"}, nil},
+		{url.Values{"Addresses.count": []string{"1"},
+			"Addresses.0.Street": []string{"a"}}, true, nil, nil},
+	}
+	for i, test := range tests {
+		data := TestFormsetData{}
+		form := NewForm(&data, nil)
+		form.Formsets = []Formset{testFormset()}
+		if valid := form.Fill(test.Values); valid != test.Valid {
+			t.Errorf("Test %v: Fill returned %v, should be %v", i, valid,
+				test.Valid)
+		}
+		if !reflect.DeepEqual(form.errors["Addresses"], test.FormsetErrors) {
+			t.Errorf("Test %v: Formset errors are %v, should be %v", i,
+				form.errors["Addresses"], test.FormsetErrors)
+		}
+		for id, errors := range test.RowErrors {
+			if !reflect.DeepEqual(form.errors[id], errors) {
+				t.Errorf("Test %v: Errors of %q are %v, should be %v", i, id,
+					form.errors[id], errors)
+			}
+		}
+	}
+}
+
+func TestFormsetRenderData(t *testing.T) {
+	data := TestFormsetData{Addresses: []TestAddress{
+		TestAddress{"First", 1, ""},
+		TestAddress{"", 2, ""}}}
+	form := NewForm(&data, nil)
+	form.Formsets = []Formset{testFormset()}
+	form.validate()
+	renderData := form.RenderData()
+	if len(renderData.Formsets) != 1 {
+		t.Fatalf("RenderData should contain 1 formset, got %v",
+			len(renderData.Formsets))
+	}
+	formset := renderData.Formsets[0]
+	if formset.Count !=
+		`<input id="Addresses.count" type="hidden" name="Addresses.count" value="2"/>` {
+		t.Errorf("Count input is %q", formset.Count)
+	}
+	if len(formset.Rows) != 2 {
+		t.Fatalf("Formset should have 2 rows, got %v", len(formset.Rows))
+	}
+	row := formset.Rows[1]
+	if row.Index != 1 || len(row.Fields) != 2 {
+		t.Fatalf("Row has index %v and %v fields", row.Index, len(row.Fields))
+	}
+	if row.Fields[0].Id != "Addresses.1.Street" ||
+		!reflect.DeepEqual(row.Fields[0].Errors, []string{"Req!"}) {
+		t.Errorf("Street field of row 1 is %v", row.Fields[0])
+	}
+	if row.Fields[1].Input !=
+		`<input id="Addresses.1.Number" type="text" name="Addresses.1.Number" value="2"/>` {
+		t.Errorf("Number input of row 1 is %q", row.Fields[1].Input)
+	}
+	if row.Delete !=
+		`<input id="Addresses.1.delete" type="checkbox" name="Addresses.1.delete" value="true"/>` {
+		t.Errorf("Delete input of row 1 is %q", row.Delete)
+	}
+	if row.Order !=
+		`<input id="Addresses.1.order" type="hidden" name="Addresses.1.order" value="1"/>` {
+		t.Errorf("Order input of row 1 is %q", row.Order)
+	}
+	out, err := form.Render(PlainTheme())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(string(out), `name="Addresses.0.Street" value="First"`) {
+		t.Errorf("Rendered form does not contain row 0:\n%v", out)
+	}
+}
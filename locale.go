@@ -0,0 +1,250 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"encoding"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale configures the formatting and parsing of numbers, currency amounts
+// and dates according to the conventions of the user.
+//
+// It applies to fields with a Text or CurrencyWidget, including fields
+// without widget. Other widgets, e.g. DateWidget, use the formats of the
+// corresponding HTML inputs which are localized by the browser.
+type Locale struct {
+	// Decimal is the decimal separator. Defaults to ".".
+	Decimal string
+	// Group separates groups of thousands, e.g. ",". Numbers are not
+	// grouped if empty.
+	Group string
+	// DateLayout is the layout of dates, e.g. "02.01.2006". Dates are not
+	// localized if empty.
+	DateLayout string
+	// Currency is the format of currency amounts with the formatted number
+	// as only argument, e.g. "%v €". Defaults to "%v".
+	Currency string
+}
+
+var (
+	// EnglishLocale uses the conventions of the USA.
+	EnglishLocale = Locale{Decimal: ".", Group: ",", DateLayout: "01/02/2006",
+		Currency: "$%v"}
+	// GermanLocale uses the conventions of Germany.
+	GermanLocale = Locale{Decimal: ",", Group: ".", DateLayout: "02.01.2006",
+		Currency: "%v €"}
+)
+
+// CurrencyWidget renders a text input for currency amounts.
+type CurrencyWidget struct {
+	// Digits is the number of fraction digits of amounts, e.g. 2.
+	Digits int
+}
+
+func (t CurrencyWidget) HTML(field string, value interface{}) template.HTML {
+	if _, ok := value.(string); !ok {
+		if formatted, ok := new(Locale).format(value, t); ok {
+			value = formatted
+		}
+	}
+	return renderWidget("currency", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// localized returns true if values of the given widget are localized.
+func localized(widget Widget) bool {
+	switch widget.(type) {
+	case nil, Text, *Text, CurrencyWidget, *CurrencyWidget:
+		return true
+	}
+	return false
+}
+
+var (
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isNumber returns true if the given type is a plain number type, i.e. a
+// numeric type without a custom text representation.
+func isNumber(t reflect.Type) bool {
+	if t.Implements(stringerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(stringerType) ||
+		reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// format formats the given value for the given widget if the locale applies
+// to them.
+func (l *Locale) format(value interface{}, widget Widget) (string, bool) {
+	if l == nil || !localized(widget) {
+		return "", false
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", isNumber(v.Type().Elem()) ||
+				v.Type().Elem() == timeType && l.DateLayout != ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", false
+	}
+	if v.Type() == timeType && l.DateLayout != "" {
+		return formatTime(v.Interface(), l.DateLayout), true
+	}
+	if !isNumber(v.Type()) {
+		return "", false
+	}
+	digits := -1
+	currency, isCurrency := widget.(CurrencyWidget)
+	if ptr, ok := widget.(*CurrencyWidget); ok {
+		currency, isCurrency = *ptr, true
+	}
+	if isCurrency {
+		digits = currency.Digits
+	}
+	var number string
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		number = strconv.FormatFloat(v.Float(), 'f', digits, v.Type().Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		number = strconv.FormatUint(v.Uint(), 10)
+	default:
+		number = strconv.FormatInt(v.Int(), 10)
+	}
+	if digits > 0 && !strings.Contains(number, ".") {
+		number += "." + strings.Repeat("0", digits)
+	}
+	number = l.localize(number)
+	if isCurrency {
+		return fmt.Sprintf(l.currency(), number), true
+	}
+	return number, true
+}
+
+// currency returns the currency format of the locale.
+func (l *Locale) currency() string {
+	if l.Currency == "" {
+		return "%v"
+	}
+	return l.Currency
+}
+
+// decimal returns the decimal separator of the locale.
+func (l *Locale) decimal() string {
+	if l.Decimal == "" {
+		return "."
+	}
+	return l.Decimal
+}
+
+// localize converts the given number formatted by strconv to the
+// conventions of the locale.
+func (l *Locale) localize(number string) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	integer, fraction := number, ""
+	if dot := strings.IndexByte(number, '.'); dot >= 0 {
+		integer, fraction = number[:dot], l.decimal()+number[dot+1:]
+	}
+	if l.Group != "" {
+		var grouped strings.Builder
+		for i, digit := range integer {
+			if i > 0 && (len(integer)-i)%3 == 0 {
+				grouped.WriteString(l.Group)
+			}
+			grouped.WriteRune(digit)
+		}
+		integer = grouped.String()
+	}
+	return sign + integer + fraction
+}
+
+// delocalize converts the given value submitted for a field of the given
+// type with the given widget to the syntax expected by stringToValue.
+//
+// Returns an inputError if a number is grouped incorrectly.
+func (l *Locale) delocalize(value string, target reflect.Type,
+	widget Widget) (string, error) {
+	_, isCurrency := widget.(CurrencyWidget)
+	if _, ok := widget.(*CurrencyWidget); ok {
+		isCurrency = true
+	}
+	if l == nil {
+		if !isCurrency {
+			return value, nil
+		}
+		l = new(Locale)
+	}
+	if !localized(widget) {
+		return value, nil
+	}
+	target = indirect(target)
+	if target == timeType && l.DateLayout != "" {
+		if date, err := time.Parse(l.DateLayout, value); err == nil {
+			return date.Format("2006-01-02"), nil
+		}
+		return value, nil
+	}
+	if !isNumber(target) {
+		return value, nil
+	}
+	number := strings.TrimSpace(value)
+	if isCurrency {
+		format := l.currency()
+		if i := strings.Index(format, "%v"); i >= 0 {
+			number = strings.TrimPrefix(number,
+				strings.TrimSpace(format[:i]))
+			number = strings.TrimSuffix(number,
+				strings.TrimSpace(format[i+2:]))
+			number = strings.TrimSpace(number)
+		}
+	}
+	integer, fraction := number, ""
+	if i := strings.Index(number, l.decimal()); i >= 0 {
+		integer, fraction = number[:i], "."+number[i+len(l.decimal()):]
+	}
+	if l.Group != "" && strings.Contains(integer, l.Group) {
+		groups := strings.Split(strings.TrimPrefix(integer, "-"), l.Group)
+		for i, group := range groups {
+			if len(group) > 3 || len(group) == 0 || i > 0 && len(group) != 3 {
+				return "", &inputError{fmt.Errorf("invalid number %q", value)}
+			}
+		}
+		integer = strings.Replace(integer, l.Group, "", -1)
+	}
+	return integer + fraction, nil
+}
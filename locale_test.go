@@ -0,0 +1,152 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestLocaleData struct {
+	Count    int
+	Size     uint16
+	Ratio    float64
+	Price    float64
+	Cents    *int64
+	Birthday time.Time
+	Timeout  time.Duration
+}
+
+func testLocaleForm(data *TestLocaleData, locale *Locale) *Form {
+	form := NewForm(data, []Field{
+		Field{Id: "Count"},
+		Field{Id: "Size"},
+		Field{Id: "Ratio"},
+		Field{Id: "Price", Widget: CurrencyWidget{Digits: 2}},
+		Field{Id: "Cents", Widget: new(CurrencyWidget)},
+		Field{Id: "Birthday"},
+		Field{Id: "Timeout", Widget: new(HiddenWidget)}})
+	form.Locale = locale
+	return form
+}
+
+func TestLocaleFormat(t *testing.T) {
+	cents := int64(-1234567)
+	data := TestLocaleData{Count: 1234567, Size: 999, Ratio: -1234.5,
+		Price: 1234.5, Cents: &cents,
+		Birthday: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		Timeout:  time.Second}
+	tests := []struct {
+		Locale   *Locale
+		Expected []string
+	}{
+		{nil, []string{"1234567", "999", "-1234.5", "1234.50", "-1234567",
+			"2026-10-16 00:00:00 &#43;0000 UTC", "1s"}},
+		{&GermanLocale, []string{"1.234.567", "999", "-1.234,5",
+			"1.234,50 €", "-1.234.567 €", "16.10.2026", "1s"}},
+		{&EnglishLocale, []string{"1,234,567", "999", "-1,234.5",
+			"$1,234.50", "$-1,234,567", "10/16/2026", "1s"}},
+		{&Locale{}, []string{"1234567", "999", "-1234.5", "1234.50",
+			"-1234567", "2026-10-16 00:00:00 &#43;0000 UTC", "1s"}},
+	}
+	for i, test := range tests {
+		form := testLocaleForm(&data, test.Locale)
+		for j, field := range form.RenderData().Fields {
+			if !strings.Contains(string(field.Input),
+				`value="`+test.Expected[j]+`"`) {
+				t.Errorf("Test %v: Input of %v is %v, should have value %q", i,
+					field.Id, field.Input, test.Expected[j])
+			}
+		}
+	}
+}
+
+func TestLocaleFill(t *testing.T) {
+	tests := []struct {
+		Locale   *Locale
+		Values   url.Values
+		Valid    bool
+		Expected TestLocaleData
+	}{
+		{&GermanLocale, url.Values{"Count": {"1.234.567"}, "Ratio": {"-1,5"},
+			"Price": {"1.234,50 €"}, "Birthday": {"16.10.2026"}}, true,
+			TestLocaleData{Count: 1234567, Ratio: -1.5, Price: 1234.5,
+				Birthday: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)}},
+		{&GermanLocale, url.Values{"Count": {"1.5"}}, false,
+			TestLocaleData{}},
+		{&GermanLocale, url.Values{"Ratio": {"1.5"}}, false,
+			TestLocaleData{}},
+		{&GermanLocale, url.Values{"Birthday": {"2026-10-16"}}, true,
+			TestLocaleData{
+				Birthday: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)}},
+		{&EnglishLocale, url.Values{"Size": {"1,000"}, "Price": {"$1,234.50"},
+			"Birthday": {"10/16/2026"}}, true,
+			TestLocaleData{Size: 1000, Price: 1234.5,
+				Birthday: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)}},
+		{&EnglishLocale, url.Values{"Count": {"12,34"}}, false,
+			TestLocaleData{}},
+		{nil, url.Values{"Ratio": {"1.5"}, "Price": {"2.25"}}, true,
+			TestLocaleData{Ratio: 1.5, Price: 2.25}},
+		{nil, url.Values{"Ratio": {"NaN"}}, false, TestLocaleData{}},
+		{nil, url.Values{"Price": {"1,234.50"}}, false, TestLocaleData{}},
+	}
+	for i, test := range tests {
+		data := TestLocaleData{}
+		form := testLocaleForm(&data, test.Locale)
+		if valid := form.Fill(test.Values); valid != test.Valid {
+			t.Errorf("Test %v: Fill(%v) returned %v, should be %v", i,
+				test.Values, valid, test.Valid)
+		}
+		if data != test.Expected {
+			t.Errorf("Test %v: Fill(%v) set %+v, should be %+v", i,
+				test.Values, data, test.Expected)
+		}
+	}
+}
+
+func TestLocaleRoundTrip(t *testing.T) {
+	cents := int64(-1234567)
+	data := TestLocaleData{Count: -1234, Size: 65535, Ratio: 0.125,
+		Price: 99999.99, Cents: &cents,
+		Birthday: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC),
+		Timeout:  time.Minute}
+	for _, locale := range []*Locale{nil, &GermanLocale, &EnglishLocale} {
+		form := testLocaleForm(&data, locale)
+		values := url.Values{}
+		for _, field := range form.RenderData().Fields {
+			value := strings.Split(strings.Split(string(field.Input),
+				`value="`)[1], `"`)[0]
+			values.Set(field.Id, strings.Replace(value, "&#43;", "+", -1))
+		}
+		values.Del("Birthday")
+		values.Del("Timeout")
+		filled := TestLocaleData{Birthday: data.Birthday,
+			Timeout: data.Timeout}
+		form = testLocaleForm(&filled, locale)
+		if !form.Fill(values) || filled.Cents == nil ||
+			*filled.Cents != cents {
+			t.Fatalf("Fill(%v) failed for %v: %+v", values, locale, filled)
+		}
+		filled.Cents = data.Cents
+		if filled != data {
+			t.Errorf("Round trip for %v returned %+v, should be %+v", locale,
+				filled, data)
+		}
+	}
+}
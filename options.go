@@ -0,0 +1,145 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"context"
+	"reflect"
+)
+
+// OptionProvider provides the options of selection widgets and of the OneOf
+// validator, e.g. from a database.
+//
+// A widget's provider is called both by Fill, to validate the submitted
+// value, and by RenderData. Providers with expensive lookups should cache
+// their options, e.g. per request in the form's Context.
+type OptionProvider interface {
+	// Options returns the available options. ctx is the form's Context or
+	// context.Background().
+	Options(ctx context.Context) ([]Option, error)
+}
+
+// StaticOptions is an OptionProvider returning fixed options.
+type StaticOptions []Option
+
+func (o StaticOptions) Options(ctx context.Context) ([]Option, error) {
+	return o, nil
+}
+
+// OptionsFunc adapts a function to an OptionProvider.
+type OptionsFunc func(ctx context.Context) ([]Option, error)
+
+func (f OptionsFunc) Options(ctx context.Context) ([]Option, error) {
+	return f(ctx)
+}
+
+// choiceWidget is implemented by widgets whose options may be provided by
+// an OptionProvider.
+type choiceWidget interface {
+	Widget
+	// choices returns the widget's provider, which are its static options
+	// if it has none, and the error message for invalid choices.
+	choices() (OptionProvider, string)
+	// withOptions returns a copy of the widget with the given static
+	// options.
+	withOptions(options []Option) Widget
+}
+
+// context returns the form's context.
+func (f Form) context() context.Context {
+	if f.Context == nil {
+		return context.Background()
+	}
+	return f.Context
+}
+
+// resolveWidget returns the widget to render a field with the given widget.
+//
+// The options of widgets with an OptionProvider are obtained using the
+// form's context. Returns an error if this fails.
+func (f Form) resolveWidget(widget Widget) (Widget, error) {
+	if widget == nil {
+		return new(Text), nil
+	}
+	choice, ok := widget.(choiceWidget)
+	if !ok {
+		return widget, nil
+	}
+	provider, _ := choice.choices()
+	if _, ok := provider.(StaticOptions); ok {
+		return widget, nil
+	}
+	options, err := provider.Options(f.context())
+	return choice.withOptions(options), err
+}
+
+// validateChoice checks the given value of the given field against the
+// enabled options of the field's widget.
+//
+// Returns the validation errors and any error of the provider.
+func (f *Form) validateChoice(field Field, value interface{}) ([]string,
+	error) {
+	choice, ok := field.Widget.(choiceWidget)
+	if !ok {
+		return nil, nil
+	}
+	provider, msg := choice.choices()
+	if msg == "" {
+		msg = "Invalid choice."
+	}
+	return oneOf(f.context(), provider, msg, value)
+}
+
+// OneOf creates a Validator to check that values are among the enabled
+// options of the given provider. Empty values are accepted, use Required to
+// reject them.
+//
+// The provider is called with context.Background(). Set the Provider of a
+// SelectWidget or RadioWidget instead to validate against options which
+// depend on the form's Context.
+//
+// msg is set as validation error, also if the provider fails.
+func OneOf(p OptionProvider, msg string) Validator {
+	return func(value interface{}) []string {
+		errors, _ := oneOf(context.Background(), p, msg, value)
+		return errors
+	}
+}
+
+// oneOf checks that the given value is among the options of the given
+// provider.
+func oneOf(ctx context.Context, p OptionProvider, msg string,
+	value interface{}) ([]string, error) {
+	v := reflect.ValueOf(value)
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.IsZero() {
+		return nil, nil
+	}
+	options, err := p.Options(ctx)
+	if err != nil {
+		return []string{msg}, err
+	}
+	formatted := formatValue(v.Interface())
+	for _, option := range options {
+		if option.Value == formatted && !option.Disabled {
+			return nil, nil
+		}
+	}
+	return []string{msg}, nil
+}
@@ -0,0 +1,147 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type userKey struct{}
+
+// userOptions provides options depending on the user in the context.
+var userOptions = OptionsFunc(func(ctx context.Context) ([]Option, error) {
+	user, _ := ctx.Value(userKey{}).(string)
+	switch user {
+	case "":
+		return nil, errors.New("no user")
+	case "admin":
+		return []Option{{Value: "public", Text: "Public"},
+			{Value: "secret", Text: "Secret"}}, nil
+	}
+	return []Option{{Value: "public", Text: "Public"}}, nil
+})
+
+func TestOptionProvider(t *testing.T) {
+	tests := []struct {
+		User, Value string
+		Valid       bool
+		Options     int
+	}{
+		{"admin", "secret", true, 2},
+		{"alice", "secret", false, 1},
+		{"alice", "public", true, 1},
+		{"alice", "", true, 1},
+	}
+	for i, test := range tests {
+		data := TestData{}
+		form := NewForm(&data, []Field{
+			Field{Id: "Name", Widget: SelectWidget{Provider: userOptions}}})
+		form.Context = context.WithValue(context.Background(), userKey{},
+			test.User)
+		valid, err := form.TryFill(url.Values{"Name": {test.Value}})
+		if err != nil || valid != test.Valid {
+			t.Errorf("Test %v: TryFill returned %v, %v, should be %v", i, valid,
+				err, test.Valid)
+		}
+		renderData, err := form.TryRenderData()
+		if err != nil {
+			t.Errorf("Test %v: TryRenderData failed: %v", i, err)
+		}
+		field := renderData.Fields[0]
+		if options := strings.Count(string(field.Input), "<option"); options !=
+			test.Options {
+			t.Errorf("Test %v: Rendered %v options, should be %v", i, options,
+				test.Options)
+		}
+		if !test.Valid && strings.Join(field.Errors, "") != "Invalid choice." {
+			t.Errorf("Test %v: Errors are %v", i, field.Errors)
+		}
+	}
+}
+
+func TestOptionProviderError(t *testing.T) {
+	data := TestData{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Widget: RadioWidget{Provider: userOptions,
+			InvalidMsg: "Nope!"}}})
+	valid, err := form.TryFill(url.Values{"Name": {"public"}})
+	if valid || err == nil || err.Error() != "no user" {
+		t.Errorf("TryFill returned %v, %v", valid, err)
+	}
+	if errors := form.RenderData().Fields[0].Errors; len(errors) != 1 ||
+		errors[0] != "Nope!" {
+		t.Errorf("Errors are %v, should be [Nope!]", errors)
+	}
+	if _, err := form.Render(PlainTheme()); err == nil {
+		t.Errorf("Render should fail")
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	options := StaticOptions{{Value: "1", Text: "One"},
+		{Value: "b", Text: "Bee"}}
+	tests := []struct {
+		Value interface{}
+		Valid bool
+	}{
+		{"b", true},
+		{"c", false},
+		{1, true},
+		{2, false},
+		{"", true},
+		{nil, true},
+	}
+	for i, test := range tests {
+		errors := OneOf(options, "Invalid!")(test.Value)
+		if (errors == nil) != test.Valid {
+			t.Errorf("Test %v: OneOf(%v) returned %v", i, test.Value, errors)
+		}
+	}
+	if errors := OneOf(userOptions, "Invalid!")("public"); len(errors) != 1 {
+		t.Errorf("OneOf should fail if the provider fails, got %v", errors)
+	}
+}
+
+func TestStaticChoice(t *testing.T) {
+	options := []Option{{Value: "de", Text: "Germany"},
+		{Value: "fr", Text: "France", Disabled: true}}
+	tests := []struct {
+		Value string
+		Valid bool
+	}{
+		{"de", true},
+		{"fr", false},
+		{"xx", false},
+		{"", true},
+	}
+	for i, test := range tests {
+		for _, widget := range []Widget{SelectWidget{Options: options},
+			RadioWidget{Options: options}} {
+			data := TestData{Name: "Old"}
+			form := NewForm(&data, []Field{Field{Id: "Name", Widget: widget}})
+			valid, err := form.TryFill(url.Values{"Name": {test.Value}})
+			if err != nil || valid != test.Valid {
+				t.Errorf("Test %v: TryFill with %T returned %v, %v", i, widget,
+					valid, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed passwords/common.txt
+var commonPasswordList string
+
+// commonPasswords contains the lower case passwords of commonPasswordList.
+var commonPasswords = func() map[string]bool {
+	ret := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" &&
+			!strings.HasPrefix(line, "#") {
+			ret[line] = true
+		}
+	}
+	return ret
+}()
+
+// PasswordPolicy configures the checks of PasswordStrength. Checks with a
+// zero limit are disabled.
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// MinClasses is the minimum number of different classes of characters
+	// out of lower case letters, upper case letters, digits and others.
+	MinClasses int
+	// MinEntropy is the minimum estimated entropy in bits. It's estimated
+	// from the length and the classes of the characters. Repeated and
+	// sequential characters, e.g. "aaa" or "123", count as one bit each.
+	MinEntropy float64
+	// RejectCommon rejects passwords from a built-in list of commonly used
+	// passwords, ignoring case.
+	RejectCommon bool
+	// The errors of the checks. Default to English messages if empty.
+	LengthMsg, ClassesMsg, EntropyMsg, CommonMsg string
+}
+
+// PasswordStrength returns a Validator checking passwords according to the
+// given policy.
+//
+// Empty values are accepted, use Required to reject them.
+func PasswordStrength(policy PasswordPolicy) Validator {
+	msg := func(msg, fallback string) []string {
+		if msg == "" {
+			msg = fallback
+		}
+		return []string{msg}
+	}
+	return func(value interface{}) []string {
+		password := valueString(reflect.ValueOf(value))
+		if password == "" {
+			return nil
+		}
+		var errors []string
+		if utf8.RuneCountInString(password) < policy.MinLength {
+			errors = append(errors, msg(policy.LengthMsg, fmt.Sprintf(
+				"Password must have at least %v characters.",
+				policy.MinLength))...)
+		}
+		if classes, _ := passwordClasses(password); classes < policy.MinClasses {
+			errors = append(errors, msg(policy.ClassesMsg, fmt.Sprintf(
+				"Password must contain at least %v of lower case letters, "+
+					"upper case letters, digits and other characters.",
+				policy.MinClasses))...)
+		}
+		if policy.RejectCommon && commonPasswords[strings.ToLower(password)] {
+			errors = append(errors, msg(policy.CommonMsg,
+				"Password is too common.")...)
+		} else if passwordEntropy(password) < policy.MinEntropy {
+			errors = append(errors, msg(policy.EntropyMsg,
+				"Password is too easy to guess.")...)
+		}
+		return errors
+	}
+}
+
+// passwordClasses returns the number of classes of characters of the given
+// password and the number of possible characters of these classes.
+func passwordClasses(password string) (int, int) {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes, size := 0, 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {other, 33}} {
+		if class.present {
+			classes++
+			size += class.size
+		}
+	}
+	return classes, size
+}
+
+// passwordEntropy returns the estimated entropy of the given password in
+// bits, see PasswordPolicy.MinEntropy.
+func passwordEntropy(password string) float64 {
+	_, size := passwordClasses(password)
+	bits := math.Log2(float64(size))
+	var entropy float64
+	var previous rune
+	for i, r := range []rune(password) {
+		if i > 0 && (r == previous || r == previous+1 || r == previous-1) {
+			entropy++
+		} else {
+			entropy += bits
+		}
+		previous = r
+	}
+	return entropy
+}
+
+// ConfirmPassword returns a FormValidator checking that the values of the
+// fields with the given Ids are equal, e.g. a new password and its
+// confirmation. The error gets added to the confirming field.
+func ConfirmPassword(field, confirm, msg string) FormValidator {
+	return func(data interface{}) map[string][]string {
+		value, err := dataField(data, field)
+		if err != nil {
+			return nil
+		}
+		confirmValue, err := dataField(data, confirm)
+		if err != nil {
+			return nil
+		}
+		if valueString(value) != valueString(confirmValue) {
+			return map[string][]string{confirm: {msg}}
+		}
+		return nil
+	}
+}
+
+// valueString returns the given value formatted as string or an empty
+// string for invalid values and nil pointers.
+func valueString(value reflect.Value) string {
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	value = reflect.Indirect(value)
+	if !value.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,122 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	validator := PasswordStrength(PasswordPolicy{MinLength: 8, MinClasses: 3,
+		MinEntropy: 40, RejectCommon: true, CommonMsg: "Common!"})
+	password := "c0rrect Horse"
+	tests := []struct {
+		Value  interface{}
+		Errors []string
+	}{
+		{"", nil},
+		{(*string)(nil), nil},
+		{"Tr0ub4dor&3", nil},
+		{&password, nil},
+		{"Ab1!", []string{"Password must have at least 8 characters.",
+			"Password is too easy to guess."}},
+		{"abcdefghij", []string{"Password must contain at least 3 of lower " +
+			"case letters, upper case letters, digits and other characters.",
+			"Password is too easy to guess."}},
+		{"Aaaaaaaaaaaaaaaaa1", []string{"Password is too easy to guess."}},
+		{"PassW0rd", []string{"Common!"}},
+	}
+	for i, test := range tests {
+		if ret := validator(test.Value); !reflect.DeepEqual(ret, test.Errors) {
+			t.Errorf("Test %v: PasswordStrength(%v) = %q, should be %q", i,
+				test.Value, ret, test.Errors)
+		}
+	}
+	if ret := PasswordStrength(PasswordPolicy{})("123456"); ret != nil {
+		t.Errorf("Empty policy should accept all passwords, got %q", ret)
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		Password string
+		Entropy  float64
+	}{
+		{"aaaa", math.Log2(26) + 3},
+		{"dcba", math.Log2(26) + 3},
+		{"a1", 2 * math.Log2(36)},
+		{"aZ9?", 4 * math.Log2(95)},
+	}
+	for i, test := range tests {
+		if ret := passwordEntropy(test.Password); ret != test.Entropy {
+			t.Errorf("Test %v: passwordEntropy(%q) = %v, should be %v", i,
+				test.Password, ret, test.Entropy)
+		}
+	}
+}
+
+func TestConfirmPassword(t *testing.T) {
+	type signup struct {
+		Password, Confirm string
+	}
+	spec := NewFormSpec(Form{
+		Fields: []Field{
+			Field{Id: "Password", Validator: Required("Req!"),
+				Widget: PasswordWidget{Autocomplete: "new-password"}},
+			Field{Id: "Confirm",
+				Widget: PasswordWidget{Autocomplete: "new-password"}}},
+		Validators: []FormValidator{
+			ConfirmPassword("Password", "Confirm", "Mismatch!"),
+			func(data interface{}) map[string][]string {
+				if data.(*signup).Password == "global" {
+					return map[string][]string{"": {"Global!"}}
+				}
+				return nil
+			}}})
+	tests := []struct {
+		Password, Confirm string
+		Errors            map[string][]string
+	}{
+		{"secret", "secret", nil},
+		{"secret", "Secret", map[string][]string{"Confirm": {"Mismatch!"}}},
+		{"", "x", map[string][]string{"Password": {"Req!"},
+			"Confirm": {"Mismatch!"}}},
+		{"global", "global", map[string][]string{"": {"Global!"}}},
+	}
+	for i, test := range tests {
+		form := spec.New(&signup{})
+		valid := form.Fill(url.Values{"Password": {test.Password},
+			"Confirm": {test.Confirm}})
+		if valid != (test.Errors == nil) ||
+			!reflect.DeepEqual(form.errors, test.Errors) {
+			t.Errorf("Test %v: Fill returned %v with errors %v, should "+
+				"return errors %v", i, valid, form.errors, test.Errors)
+		}
+	}
+	data := map[string]interface{}{"A": "x", "B": nil}
+	validator := ConfirmPassword("A", "B", "Mismatch!")
+	if ret := validator(data); !reflect.DeepEqual(ret,
+		map[string][]string{"B": {"Mismatch!"}}) {
+		t.Errorf("ConfirmPassword should fail for a nil value, got %v", ret)
+	}
+	if ret := validator(nil); ret != nil {
+		t.Errorf("ConfirmPassword should ignore invalid data, got %v", ret)
+	}
+}
@@ -0,0 +1,180 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+)
+
+//go:embed themes/*.html
+var themeFS embed.FS
+
+// Renderer renders complete forms.
+type Renderer interface {
+	// Render returns the html of the form described by the given data.
+	Render(data RenderData) (template.HTML, error)
+}
+
+// TemplateData is the data passed to the "form" template of a
+// TemplateRenderer.
+type TemplateData struct {
+	RenderData
+	// Submit is the label of the submit button.
+	Submit string
+}
+
+// TemplateRenderer is a Renderer using a html/template set.
+//
+// The set must define a template named "form" which gets executed with a
+// TemplateData value.
+//
+// The input of fields may be overridden per widget kind by defining a
+// template named "widget-" followed by the kind, e.g. "widget-select". It
+// gets executed with the field's FieldRenderData and replaces the field's
+// Input, including the inputs of fields in fieldsets and formset rows.
+type TemplateRenderer struct {
+	// Template is the template set used to render forms.
+	Template *template.Template
+	// Submit is the label of the submit button.
+	Submit string
+}
+
+// Override parses the given template text as input template for widgets of
+// the given kind.
+//
+// Overrides must be set up before the first call to Render.
+func (r *TemplateRenderer) Override(kind, text string) error {
+	_, err := r.Template.New("widget-" + kind).Parse(text)
+	if err != nil {
+		return fmt.Errorf("form: Could not parse override for %q: %v", kind, err)
+	}
+	return nil
+}
+
+// Render renders the form described by the given data.
+func (r *TemplateRenderer) Render(data RenderData) (template.HTML, error) {
+	var err error
+	if data.Fields, err = r.overrideFields(data.Fields); err != nil {
+		return "", err
+	}
+	if data.Fieldsets, err = r.overrideFieldsets(data.Fieldsets); err != nil {
+		return "", err
+	}
+	if data.Ungrouped, err = r.overrideFields(data.Ungrouped); err != nil {
+		return "", err
+	}
+	formsets := make([]FormsetRenderData, len(data.Formsets))
+	for i, formset := range data.Formsets {
+		rows := make([]FormsetRowRenderData, len(formset.Rows))
+		for j, row := range formset.Rows {
+			if row.Fields, err = r.overrideFields(row.Fields); err != nil {
+				return "", err
+			}
+			rows[j] = row
+		}
+		formset.Rows = rows
+		formsets[i] = formset
+	}
+	data.Formsets = formsets
+	var out bytes.Buffer
+	err = r.Template.ExecuteTemplate(&out, "form",
+		TemplateData{RenderData: data, Submit: r.Submit})
+	if err != nil {
+		return "", fmt.Errorf("form: Could not render form: %v", err)
+	}
+	return template.HTML(out.String()), nil
+}
+
+// overrideFields returns a copy of the given fields whose Input is replaced
+// by the overrides of their kinds.
+func (r *TemplateRenderer) overrideFields(fields []FieldRenderData) (
+	[]FieldRenderData, error) {
+	ret := make([]FieldRenderData, len(fields))
+	copy(ret, fields)
+	for i, field := range ret {
+		override := r.Template.Lookup("widget-" + field.Kind)
+		if override == nil {
+			continue
+		}
+		var out bytes.Buffer
+		if err := override.Execute(&out, field); err != nil {
+			return nil, fmt.Errorf("form: Could not render field %q: %v",
+				field.Id, err)
+		}
+		ret[i].Input = template.HTML(out.String())
+	}
+	return ret, nil
+}
+
+// overrideFieldsets returns a copy of the given fieldsets whose fields are
+// overridden by overrideFields.
+func (r *TemplateRenderer) overrideFieldsets(
+	fieldsets []FieldsetRenderData) ([]FieldsetRenderData, error) {
+	if fieldsets == nil {
+		return nil, nil
+	}
+	ret := make([]FieldsetRenderData, len(fieldsets))
+	for i, fieldset := range fieldsets {
+		var err error
+		if fieldset.Fields, err = r.overrideFields(fieldset.Fields); err != nil {
+			return nil, err
+		}
+		fieldset.Fieldsets, err = r.overrideFieldsets(fieldset.Fieldsets)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = fieldset
+	}
+	return ret, nil
+}
+
+// theme returns a TemplateRenderer using the theme with the given name.
+func theme(name string) *TemplateRenderer {
+	return &TemplateRenderer{
+		Template: template.Must(template.ParseFS(themeFS,
+			"themes/"+name+".html")),
+		Submit: "Submit"}
+}
+
+// PlainTheme returns a TemplateRenderer for plain HTML5 forms.
+func PlainTheme() *TemplateRenderer {
+	return theme("plain")
+}
+
+// BootstrapTheme returns a TemplateRenderer for forms styled with Twitter
+// Bootstrap.
+func BootstrapTheme() *TemplateRenderer {
+	return theme("bootstrap")
+}
+
+// TailwindTheme returns a TemplateRenderer for forms styled with Tailwind CSS
+// utility classes.
+func TailwindTheme() *TemplateRenderer {
+	return theme("tailwind")
+}
+
+// Render renders the form using the given Renderer.
+func (f Form) Render(r Renderer) (template.HTML, error) {
+	data, err := f.TryRenderData()
+	if err != nil {
+		return "", err
+	}
+	return r.Render(data)
+}
@@ -0,0 +1,179 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThemes(t *testing.T) {
+	data := TestData{Name: "Foo <Bar>"}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Label: "Your name", Help: "Your full name",
+			Validator: Required("Req!")},
+		Field{Id: "Age", Label: "Your age", Validator: Required("Req!")},
+		Field{Id: "Title", Widget: new(HiddenWidget)}})
+	form.Action = "/signup"
+	form.validate()
+	tests := []struct {
+		Name     string
+		Renderer *TemplateRenderer
+		Contains []string
+	}{
+		{"plain", PlainTheme(), []string{
+			`<form action="/signup" method="POST"`,
+			`<label for="Name">Your name</label>`,
+			`value="Foo &lt;Bar&gt;"`,
+			`<small>Your full name</small>`,
+			`<strong class="error">Req!</strong>`,
+			`<input id="Title" type="hidden" name="Title" value=""/>`,
+			`<button type="submit">Submit</button>`}},
+		{"bootstrap", BootstrapTheme(), []string{
+			`<div class="control-group error">`,
+			`<label class="control-label" for="Age">Your age</label>`,
+			`class="btn btn-primary">Submit</button>`}},
+		{"tailwind", TailwindTheme(), []string{
+			`<label class="block text-sm font-medium text-gray-900" for="Name">`,
+			`<p class="mt-2 text-sm text-red-600">Req!</p>`}},
+	}
+	for _, test := range tests {
+		ret, err := form.Render(test.Renderer)
+		if err != nil {
+			t.Errorf("Render with theme %q failed: %v", test.Name, err)
+			continue
+		}
+		for _, expected := range test.Contains {
+			if !strings.Contains(string(ret), expected) {
+				t.Errorf("Rendered form of theme %q does not contain %q:\n%v",
+					test.Name, expected, ret)
+			}
+		}
+		if strings.Contains(string(ret), `<label for="Title">`) {
+			t.Errorf("Theme %q renders label for hidden field:\n%v",
+				test.Name, ret)
+		}
+	}
+}
+
+func TestTemplateRendererOverride(t *testing.T) {
+	data := TestData{Name: "Foo"}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Label: "Your name"},
+		Field{Id: "Age", Label: "Your age", Widget: new(TextArea)}})
+	renderer := PlainTheme()
+	renderer.Submit = "Go!"
+	err := renderer.Override("text",
+		`<input class="fancy" name="{{.Id}}" value="{{.Value}}">`)
+	if err != nil {
+		t.Fatalf("Override failed: %v", err)
+	}
+	ret, err := form.Render(renderer)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, expected := range []string{
+		`<input class="fancy" name="Name" value="Foo">`,
+		`<textarea id="Age" name="Age"`,
+		`<button type="submit">Go!</button>`} {
+		if !strings.Contains(string(ret), expected) {
+			t.Errorf("Rendered form does not contain %q:\n%v", expected, ret)
+		}
+	}
+	if err := PlainTheme().Override("text", "{{.Broken"); err == nil {
+		t.Errorf("Override with invalid template should fail")
+	}
+}
+
+func TestOverrideFieldsetsAndFormsets(t *testing.T) {
+	data := TestFormsetData{Addresses: []TestAddress{{Street: "Main"}}}
+	form := NewForm(&data, []Field{Field{Id: "Name"}})
+	form.Fieldsets = []Fieldset{Fieldset{Legend: "Outer",
+		Fieldsets: []Fieldset{Fieldset{Legend: "Inner",
+			Fields: []string{"Name"}}}}}
+	form.Formsets = []Formset{testFormset()}
+	renderer := PlainTheme()
+	if err := renderer.Override("text", `<input class="fancy" `+
+		`name="{{.Id}}">`); err != nil {
+		t.Fatalf("Override failed: %v", err)
+	}
+	ret, err := form.Render(renderer)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, expected := range []string{`<input class="fancy" name="Name">`,
+		`<input class="fancy" name="Addresses.0.Street">`} {
+		if !strings.Contains(string(ret), expected) {
+			t.Errorf("Rendered form does not contain %q:\n%v", expected, ret)
+		}
+	}
+}
+
+func TestThemeFieldsets(t *testing.T) {
+	data := TestData{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Name", Label: "Your name"},
+		Field{Id: "Age", Label: "Your age"},
+		Field{Id: "Title", Widget: new(HiddenWidget)}})
+	form.AddError("Title", "Bad title!")
+	form.Fieldsets = []Fieldset{
+		Fieldset{Legend: "Name", Fields: []string{"Name"},
+			Fieldsets: []Fieldset{
+				Fieldset{Legend: "Details", Fields: []string{"Age"}}}}}
+	ret, err := form.Render(PlainTheme())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, expected := range []string{
+		"<fieldset>\n<legend>Name</legend>\n<p>\n" +
+			`<label for="Name">Your name</label>`,
+		"<fieldset>\n<legend>Details</legend>\n<p>\n" +
+			`<label for="Age">Your age</label>`,
+		"</fieldset>\n</fieldset>\n" +
+			`<input id="Title" type="hidden" name="Title" value=""/>` +
+			"\n<p><strong class=\"error\">Bad title!</strong></p>"} {
+		if !strings.Contains(string(ret), expected) {
+			t.Errorf("Rendered form does not contain %q:\n%v", expected, ret)
+		}
+	}
+}
+
+func TestThemeRadio(t *testing.T) {
+	data := struct{ Color string }{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Color", Label: "Your color", Widget: RadioWidget{
+			Options: []Option{{Value: "red", Text: "Red"},
+				{Value: "blue", Text: "Blue"}}}}})
+	if tag := form.RenderData().Fields[0].LabelTag; tag !=
+		`<label for="Color-0">Your color</label>` {
+		t.Errorf("LabelTag is %v", tag)
+	}
+	for name, theme := range map[string]*TemplateRenderer{
+		"plain": PlainTheme(), "bootstrap": BootstrapTheme(),
+		"tailwind": TailwindTheme()} {
+		ret, err := form.Render(theme)
+		if err != nil {
+			t.Errorf("Render with theme %q failed: %v", name, err)
+			continue
+		}
+		if !strings.Contains(string(ret), ">Your color</legend>") ||
+			strings.Contains(string(ret), `for="Color"`) {
+			t.Errorf("Theme %q should render a legend for the radio "+
+				"buttons:\n%v", name, ret)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"html"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// RichTextWidget renders a textarea for HTML to be enhanced by a client side
+// rich text editor.
+//
+// The textarea has the class "richtext". Editors are attached using Attrs,
+// e.g. data attributes configuring the editor, and Script. Submitted HTML
+// is sanitized by Fill, so it's safe to store and render the field's value
+// as template.HTML.
+type RichTextWidget struct {
+	// Attrs contains additional attributes of the textarea. Event handlers,
+	// invalid names and the attributes id, name and class are dropped.
+	Attrs map[string]string
+	// Script optionally is rendered after the textarea, e.g. a script
+	// element initializing the editor.
+	Script template.HTML
+	// Policy is used to sanitize the submitted HTML. Defaults to
+	// DefaultPolicy() if nil.
+	Policy *Policy
+}
+
+func (t RichTextWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("richtext", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value),
+		Attrs: htmlAttrs(t.Attrs, reservedRichTextAttrs)})
+}
+
+func (t RichTextWidget) sanitize(value string) string {
+	if t.Policy == nil {
+		return defaultPolicy.Sanitize(value)
+	}
+	return t.Policy.Sanitize(value)
+}
+
+// reservedRichTextAttrs contains the attributes set by RichTextWidget.
+var reservedRichTextAttrs = map[string]bool{
+	"id": true, "name": true, "class": true}
+
+// sanitizingWidget is implemented by widgets whose submitted values need to
+// be sanitized before being set.
+type sanitizingWidget interface {
+	// sanitize returns the sanitized value.
+	sanitize(value string) string
+}
+
+// sanitize returns the given value sanitized by the given widget.
+func sanitize(widget Widget, value string) string {
+	if widget, ok := widget.(sanitizingWidget); ok {
+		return widget.sanitize(value)
+	}
+	return value
+}
+
+// Policy is an allowlist of HTML elements and attributes used to sanitize
+// HTML.
+type Policy struct {
+	// Elements maps the names of the allowed elements to the names of their
+	// allowed attributes. Names are lower case.
+	Elements map[string][]string
+	// URLSchemes contains the allowed schemes of URLs in href, src and cite
+	// attributes, e.g. "https". Relative URLs are always allowed.
+	URLSchemes []string
+}
+
+// DefaultPolicy returns a new policy allowing basic text formatting, lists,
+// tables, links and images with http, https and mailto URLs.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Elements: map[string][]string{
+			"a": {"href", "title"}, "abbr": {"title"}, "b": nil,
+			"blockquote": {"cite"}, "br": nil, "code": nil, "del": nil,
+			"em": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil,
+			"h6": nil, "hr": nil, "i": nil,
+			"img": {"src", "alt", "title", "width", "height"}, "li": nil,
+			"ol": nil, "p": nil, "pre": nil, "s": nil, "strong": nil,
+			"sub": nil, "sup": nil, "table": nil, "tbody": nil,
+			"td": {"colspan", "rowspan"}, "th": {"colspan", "rowspan"},
+			"thead": nil, "tr": nil, "u": nil, "ul": nil},
+		URLSchemes: []string{"http", "https", "mailto"},
+	}
+}
+
+// defaultPolicy is used by widgets without a policy.
+var defaultPolicy = DefaultPolicy()
+
+var (
+	// voidElements contains the elements without content.
+	voidElements = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true,
+		"hr": true, "img": true, "input": true, "link": true, "meta": true,
+		"source": true, "track": true, "wbr": true}
+	// rawTextElements contains the elements whose content is not markup.
+	// It's dropped together with the element unless allowed.
+	rawTextElements = map[string]bool{
+		"iframe": true, "noembed": true, "noframes": true, "noscript": true,
+		"script": true, "style": true, "textarea": true, "title": true,
+		"xmp": true}
+	// urlAttrs contains the attributes holding URLs.
+	urlAttrs = map[string]bool{"href": true, "src": true, "cite": true}
+)
+
+// Sanitize returns the given HTML with all elements and attributes not
+// allowed by the policy removed.
+//
+// The content of removed elements is kept, except for elements like script
+// or style. Comments are removed, text is escaped and unclosed elements get
+// closed, so that the result can't affect markup surrounding it.
+func (p *Policy) Sanitize(src string) string {
+	var out strings.Builder
+	var open []string
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			lt = len(src)
+		}
+		out.WriteString(html.EscapeString(html.UnescapeString(src[:lt])))
+		src = src[lt:]
+		if len(src) == 0 {
+			break
+		}
+		if strings.HasPrefix(src, "<!--") {
+			src = skipPast(src[4:], "-->")
+			continue
+		}
+		if strings.HasPrefix(src, "<!") || strings.HasPrefix(src, "<?") {
+			src = skipPast(src, ">")
+			continue
+		}
+		tag, rest, ok := parseTag(src)
+		if !ok && rest == "" {
+			// Unterminated tags extend to the end, there's nothing left
+			// to parse.
+			out.WriteString(html.EscapeString(html.UnescapeString(src)))
+			break
+		}
+		if !ok {
+			out.WriteString("&lt;")
+			src = src[1:]
+			continue
+		}
+		src = rest
+		allowed, isAllowed := p.Elements[tag.name]
+		switch {
+		case tag.closing:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tag.name {
+					for _, name := range reverse(open[i:]) {
+						out.WriteString("</" + name + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		case !isAllowed && rawTextElements[tag.name]:
+			src = skipRawText(src, tag.name)
+		case isAllowed:
+			out.WriteString("<" + tag.name)
+			p.writeAttrs(&out, tag.attrs, allowed)
+			out.WriteString(">")
+			if !voidElements[tag.name] {
+				open = append(open, tag.name)
+			}
+		}
+	}
+	for _, name := range reverse(open) {
+		out.WriteString("</" + name + ">")
+	}
+	return out.String()
+}
+
+// writeAttrs writes the given attributes which are in the given list of
+// allowed attributes.
+func (p *Policy) writeAttrs(out *strings.Builder, attrs map[string]string,
+	allowed []string) {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := attrs[name]
+		if !containsString(allowed, name) ||
+			urlAttrs[name] && !p.allowedURL(value) {
+			continue
+		}
+		out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+}
+
+// allowedURL returns true iff the given URL is relative or has an allowed
+// scheme.
+func (p *Policy) allowedURL(url string) bool {
+	// Browsers ignore whitespace and control characters in schemes.
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	return containsString(p.URLSchemes, strings.ToLower(url[:colon]))
+}
+
+// htmlTag is a start or end tag parsed by parseTag.
+type htmlTag struct {
+	// name is the lower case name of the element.
+	name    string
+	closing bool
+	// attrs maps the lower case attribute names to their unescaped values.
+	attrs map[string]string
+}
+
+// parseTag parses the tag at the start of the given string.
+//
+// Returns the tag, the rest of the string and true or false if the string
+// doesn't start with a complete tag. The rest is empty if the tag is not
+// terminated, i.e. if it extends to the end of the string.
+func parseTag(src string) (htmlTag, string, bool) {
+	tag := htmlTag{attrs: make(map[string]string)}
+	i := 1
+	if i < len(src) && src[i] == '/' {
+		tag.closing = true
+		i++
+	}
+	start := i
+	for i < len(src) && (isASCIILetter(src[i]) ||
+		i > start && src[i] >= '0' && src[i] <= '9') {
+		i++
+	}
+	if i == start {
+		return tag, src, false
+	}
+	tag.name = strings.ToLower(src[start:i])
+	for {
+		for i < len(src) && (isHTMLSpace(src[i]) || src[i] == '/') {
+			i++
+		}
+		if i == len(src) {
+			return tag, "", false
+		}
+		if src[i] == '>' {
+			return tag, src[i+1:], true
+		}
+		start = i
+		for i < len(src) && !isHTMLSpace(src[i]) &&
+			!strings.ContainsRune("/>=", rune(src[i])) {
+			i++
+		}
+		name := strings.ToLower(src[start:i])
+		for i < len(src) && isHTMLSpace(src[i]) {
+			i++
+		}
+		var value string
+		if i < len(src) && src[i] == '=' {
+			i++
+			for i < len(src) && isHTMLSpace(src[i]) {
+				i++
+			}
+			if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+				end := strings.IndexByte(src[i+1:], src[i])
+				if end < 0 {
+					return tag, "", false
+				}
+				value = src[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start = i
+				for i < len(src) && !isHTMLSpace(src[i]) && src[i] != '>' {
+					i++
+				}
+				value = src[start:i]
+			}
+		}
+		if _, ok := tag.attrs[name]; !ok && name != "" {
+			tag.attrs[name] = html.UnescapeString(value)
+		}
+	}
+}
+
+// skipRawText returns the given string after the end tag of the raw text
+// element with the given name.
+func skipRawText(src, name string) string {
+	for i := 0; i+2+len(name) <= len(src); i++ {
+		if src[i] == '<' && src[i+1] == '/' &&
+			strings.EqualFold(src[i+2:i+2+len(name)], name) {
+			tag, rest, ok := parseTag(src[i:])
+			if ok && tag.name == name || rest == "" {
+				return rest
+			}
+			if ok {
+				// Skip other end tags, e.g. </scripts>.
+				i = len(src) - len(rest) - 1
+			}
+		}
+	}
+	return ""
+}
+
+// skipPast returns the given string after the first occurrence of sep or an
+// empty string if sep doesn't occur.
+func skipPast(src, sep string) string {
+	if i := strings.Index(src, sep); i >= 0 {
+		return src[i+len(sep):]
+	}
+	return ""
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// reverse returns the given strings in reverse order.
+func reverse(values []string) []string {
+	ret := make([]string, len(values))
+	for i, value := range values {
+		ret[len(values)-1-i] = value
+	}
+	return ret
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
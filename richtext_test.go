@@ -0,0 +1,117 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct{ In, Out string }{
+		{"Hello <b>World</b>!", "Hello <b>World</b>!"},
+		{"<P Class=x>a &amp; b &lt; c</p>", "<p>a &amp; b &lt; c</p>"},
+		{"a < b > c & d", "a &lt; b &gt; c &amp; d"},
+		{"<script>alert(1)</script>x", "x"},
+		{"<SCRIPT>a</scriptx></Script >b", "b"},
+		{"<style>p {}", ""},
+		{"<div><em>kept</em></div>", "<em>kept</em>"},
+		{`<img src="x.png" onerror="alert(1)">`, `<img src="x.png">`},
+		{`<a href="javascript:alert(1)" title='t'>x</a>`, `<a title="t">x</a>`},
+		{`<a href=" JaVa&#x09;script&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="/path?a=1&amp;b=2">x</a>`,
+			`<a href="/path?a=1&amp;b=2">x</a>`},
+		{`<a href="mailto:a@example.com">x</a>`,
+			`<a href="mailto:a@example.com">x</a>`},
+		{`<a href="data:text/html,x">x</a>`, `<a>x</a>`},
+		{`<img src=x.png alt="a&quot;b">`, `<img alt="a&#34;b" src="x.png">`},
+		{"<ul><li>a<li>b</ul>", "<ul><li>a<li>b</li></li></ul>"},
+		{"<p><b>unclosed", "<p><b>unclosed</b></p>"},
+		{"</p>stray</b>", "stray"},
+		{"a<!-- <script> -->b<!doctype html><?xml?>c", "abc"},
+		{`<b title="unterminated>x`, "&lt;b title=&#34;unterminated&gt;x"},
+		{"<br/><hr>", "<br><hr>"},
+		{"<b <i>x", "<b>x</b>"},
+		{"<script>a</scripts x>b</script>c", "c"},
+		{"<script>a</script x='", ""},
+	}
+	policy := DefaultPolicy()
+	for i, test := range tests {
+		if ret := policy.Sanitize(test.In); ret != test.Out {
+			t.Errorf("Test %v: Sanitize(%q) = %q, should be %q", i, test.In,
+				ret, test.Out)
+		}
+	}
+	policy = &Policy{Elements: map[string][]string{"a": {"href"}},
+		URLSchemes: []string{"ftp"}}
+	in := `<p><a href="ftp://example.com">x</a><a href="https://x">y</a></p>`
+	out := `<a href="ftp://example.com">x</a><a>y</a>`
+	if ret := policy.Sanitize(in); ret != out {
+		t.Errorf("Sanitize(%q) = %q, should be %q", in, ret, out)
+	}
+}
+
+func TestSanitizeLinear(t *testing.T) {
+	policy := DefaultPolicy()
+	for _, in := range []string{strings.Repeat("<a ", 100000),
+		`<b title="` + strings.Repeat(`<a title="`, 100000),
+		"<script>" + strings.Repeat("</script", 100000),
+		"<script>" + strings.Repeat("</scripts ", 100000) + ">"} {
+		start := time.Now()
+		policy.Sanitize(in)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Sanitize of %q... took %v", in[:20], elapsed)
+		}
+	}
+}
+
+func TestRichTextWidget(t *testing.T) {
+	widget := RichTextWidget{
+		Attrs: map[string]string{"data-toolbar": "bold italic",
+			"class": "other", "onload": "evil()"},
+		Script: `<script>initEditor("foo")</script>`}
+	expected := `<textarea class="richtext" id="foo" name="foo" ` +
+		`data-toolbar="bold italic">&lt;b&gt;x&lt;/b&gt;</textarea>` +
+		`<script>initEditor("foo")</script>`
+	if ret := widget.HTML("foo", "<b>x</b>"); string(ret) != expected {
+		t.Errorf(`HTML("foo", "<b>x</b>") = %v, should be %v`, ret, expected)
+	}
+
+	data := struct{ Body, Strict, Legacy string }{}
+	form := NewForm(&data, []Field{
+		Field{Id: "Body", Widget: widget},
+		Field{Id: "Strict", Widget: RichTextWidget{
+			Policy: &Policy{Elements: map[string][]string{"i": nil}}}},
+		Field{Id: "Legacy", Widget: new(AlohaEditor)}})
+	in := `<p onclick="x()">a<script>b</script><i>c</i></p>`
+	if !form.Fill(url.Values{"Body": {in}, "Strict": {in},
+		"Legacy": {in}}) {
+		t.Fatalf("Fill failed")
+	}
+	if data.Body != "<p>a<i>c</i></p>" {
+		t.Errorf("Body = %q, should be sanitized", data.Body)
+	}
+	if data.Strict != "a<i>c</i>" {
+		t.Errorf("Strict = %q, should be sanitized using the policy",
+			data.Strict)
+	}
+	if data.Legacy != "<p>a<i>c</i></p>" {
+		t.Errorf("Legacy = %q, should be sanitized", data.Legacy)
+	}
+}
@@ -0,0 +1,67 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// sign returns a token containing the payload and its HMAC-SHA256 signature
+// using the given key.
+//
+// The purpose, e.g. "wizard:signup", is signed along with the payload, so
+// that tokens issued for one purpose are rejected for others, even if they
+// share the key.
+func sign(key []byte, purpose string, payload []byte) string {
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac(key, purpose, payload))
+}
+
+// verify checks the signature of a token created by sign for the given
+// purpose and returns its payload.
+func verify(key []byte, purpose, token string) ([]byte, error) {
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return nil, fmt.Errorf("form: Malformed signed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:dot])
+	if err != nil {
+		return nil, fmt.Errorf("form: Malformed signed token: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("form: Malformed signed token: %v", err)
+	}
+	if !hmac.Equal(signature, mac(key, purpose, payload)) {
+		return nil, fmt.Errorf("form: Invalid signature")
+	}
+	return payload, nil
+}
+
+// mac returns the HMAC-SHA256 of the given purpose and payload. The purpose
+// is terminated by a zero byte, so that it can't be extended by the payload.
+func mac(key []byte, purpose string, payload []byte) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(purpose))
+	hash.Write([]byte{0})
+	hash.Write(payload)
+	return hash.Sum(nil)
+}
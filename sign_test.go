@@ -0,0 +1,38 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	key := []byte("secret")
+	token := sign(key, "test", []byte("payload"))
+	payload, err := verify(key, "test", token)
+	if err != nil || string(payload) != "payload" {
+		t.Errorf("verify(sign(%q)) = %q, %v", "payload", payload, err)
+	}
+	for _, token := range []string{
+		"", "foo", token + "x", "x" + token,
+		sign([]byte("other"), "test", []byte("payload")),
+		sign(key, "other", []byte("payload")),
+		sign(key, "tes", []byte("t\x00payload")),
+	} {
+		if _, err := verify(key, "test", token); err == nil {
+			t.Errorf("verify(%q) should fail", token)
+		}
+	}
+}
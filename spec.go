@@ -0,0 +1,128 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+// FormSpec is an immutable form definition.
+//
+// A FormSpec is meant to be defined once, e.g. during package initialization,
+// and may be used by multiple goroutines to create per-request forms:
+//
+//	var signupSpec = form.NewFormSpec(form.Form{
+//		Fields: []form.Field{...},
+//		Action: "/signup"})
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		data := signupData{}
+//		signupForm := signupSpec.New(&data)
+//		...
+//	}
+type FormSpec struct {
+	form Form
+}
+
+// NewFormSpec creates a FormSpec from the given form definition.
+//
+// The definition gets copied, so later changes to it don't affect the spec.
+// Data and errors of the definition are ignored.
+func NewFormSpec(definition Form) *FormSpec {
+	spec := FormSpec{form: Form{
+		Fields:     copyFields(definition.Fields),
+		Fieldsets:  copyFieldsets(definition.Fieldsets),
+		Action:     definition.Action,
+		Locale:     definition.Locale,
+		Location:   definition.Location,
+		InvalidMsg: definition.InvalidMsg,
+		Validators: append([]FormValidator(nil), definition.Validators...)}}
+	if definition.Formsets != nil {
+		spec.form.Formsets = make([]Formset, len(definition.Formsets))
+		for i, formset := range definition.Formsets {
+			formset.Fields = copyFields(formset.Fields)
+			spec.form.Formsets[i] = formset
+		}
+	}
+	if definition.AntiSpam != nil {
+		antiSpam := *definition.AntiSpam
+		antiSpam.Key = append([]byte(nil), antiSpam.Key...)
+		spec.form.AntiSpam = &antiSpam
+	}
+	if definition.Strict != nil {
+		strict := *definition.Strict
+		strict.Allow = append([]string(nil), strict.Allow...)
+		spec.form.Strict = &strict
+	}
+	return &spec
+}
+
+// copyFields returns a copy of the given fields whose capacity equals its
+// length, so that appending to it never modifies the copy.
+func copyFields(fields []Field) []Field {
+	if fields == nil {
+		return nil
+	}
+	ret := make([]Field, len(fields))
+	copy(ret, fields)
+	return ret
+}
+
+// copyFieldsets returns a deep copy of the given fieldsets.
+func copyFieldsets(fieldsets []Fieldset) []Fieldset {
+	if fieldsets == nil {
+		return nil
+	}
+	ret := make([]Fieldset, len(fieldsets))
+	for i, fieldset := range fieldsets {
+		fieldset.Fields = append([]string(nil), fieldset.Fields...)
+		fieldset.Fieldsets = copyFieldsets(fieldset.Fieldsets)
+		ret[i] = fieldset
+	}
+	return ret
+}
+
+// New creates a new Form of the spec bound to the given data.
+//
+// The form shares the definition of the spec. Its Fields, Fieldsets,
+// Formsets, Validators, Strict and AntiSpam must not be modified in place;
+// assign new values instead.
+//
+// In panics if data is not a map or a pointer to a struct.
+func (s *FormSpec) New(data interface{}) *Form {
+	form, err := s.TryNew(data)
+	if err != nil {
+		panic(err.Error())
+	}
+	return form
+}
+
+// TryNew is like New but returns an error instead of panicking if data is
+// not a map or a pointer to a struct.
+func (s *FormSpec) TryNew(data interface{}) (*Form, error) {
+	if err := checkData(data); err != nil {
+		return nil, err
+	}
+	form := s.form
+	form.data = data
+	return &form, nil
+}
+
+// Check checks the spec against the given data, see Form.Check.
+func (s *FormSpec) Check(data interface{}) error {
+	form, err := s.TryNew(data)
+	if err != nil {
+		return err
+	}
+	return form.Check()
+}
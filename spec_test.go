@@ -0,0 +1,104 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func signupFields() []Field {
+	return []Field{
+		Field{Id: "Name", Label: "Your name", Help: "Your full name",
+			Validator: Required("Req!")},
+		Field{Id: "Age", Label: "Your age", Help: "Years since your birth.",
+			Validator: And(Required("Req!"), Regex("^[0-9]+$", "Number!"))},
+		Field{Id: "Title", Label: "Your title",
+			Widget: SelectWidget{Options: []Option{
+				Option{Text: "None"}, Option{Value: "Dr.", Text: "Dr."},
+				Option{Value: "Prof.", Text: "Prof."}}}},
+	}
+}
+
+func TestFormSpec(t *testing.T) {
+	definition := Form{Fields: signupFields(), Action: "/signup",
+		Fieldsets: []Fieldset{Fieldset{Legend: "You",
+			Fields: []string{"Name", "Age"}}},
+		Strict: &StrictMode{Allow: []string{"csrf"}}}
+	spec := NewFormSpec(definition)
+	definition.Fields[0].Label = "Changed"
+	definition.Fieldsets[0].Fields[0] = "Title"
+	definition.Strict.Allow[0] = "changed"
+	if err := spec.Check(&TestData{}); err != nil {
+		t.Errorf("Check failed: %v", err)
+	}
+	if err := spec.Check(TestData{}); err == nil {
+		t.Errorf("Check should fail for non pointer data")
+	}
+	if _, err := spec.TryNew(nil); err == nil {
+		t.Errorf("TryNew should fail for nil data")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := TestData{}
+			form := spec.New(&data)
+			valid := form.Fill(url.Values{"Name": []string{"Foo"},
+				"Age": []string{"x"}, "Title": []string{""},
+				"csrf": []string{"token"}})
+			if valid || data.Name != "Foo" {
+				t.Errorf("Fill returned %v, data is %v", valid, data)
+			}
+			renderData := form.RenderData()
+			if renderData.Action != "/signup" ||
+				renderData.Fields[0].Label != "Your name" ||
+				renderData.Fieldsets[0].Fields[0].Id != "Name" ||
+				len(renderData.Errors) != 0 {
+				t.Errorf("RenderData is %v", renderData)
+			}
+			form.Fields = append(form.Fields, Field{Id: "Extra"})
+		}()
+	}
+	wg.Wait()
+	if form := spec.New(&TestData{}); len(form.Fields) != 3 ||
+		len(form.RenderData().Errors) != 0 {
+		t.Errorf("Forms of a spec should not affect each other")
+	}
+}
+
+func BenchmarkNewFormPerRequest(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data := TestData{}
+		form := NewForm(&data, signupFields())
+		form.Action = "/signup"
+	}
+}
+
+func BenchmarkFormSpecNew(b *testing.B) {
+	b.ReportAllocs()
+	spec := NewFormSpec(Form{Fields: signupFields(), Action: "/signup"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data := TestData{}
+		spec.New(&data)
+	}
+}
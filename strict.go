@@ -0,0 +1,152 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// StrictMode configures strict binding of submitted parameters.
+//
+// In strict mode, Fill adds a global error for each submitted parameter
+// which does not belong to the form, for each expected parameter which is
+// missing and for each parameter which has been submitted more than once.
+// Parameters of file inputs are not expected as they are not part of the
+// values passed to Fill. Neither are parameters of fields with a condition,
+// as hidden inputs may not be submitted by the client, nor parameters which
+// browsers omit if nothing is selected, i.e. of fields bound to bools, e.g.
+// checkboxes, and of RadioWidgets.
+type StrictMode struct {
+	// Allow lists parameters which may be submitted in addition to the
+	// form's fields, e.g. a CSRF token or the name of the submit button.
+	Allow []string
+	// UnknownMsg, MissingMsg and DuplicateMsg are the errors for unknown,
+	// missing and duplicated parameters. They are used as format strings
+	// with the parameter's name as only argument. Defaults to English
+	// messages if empty.
+	UnknownMsg, MissingMsg, DuplicateMsg string
+}
+
+// checkParams checks the given values according to the form's strict mode.
+//
+// Returns true iff no problems have been found.
+func (f *Form) checkParams(values url.Values) bool {
+	unknownMsg := f.Strict.UnknownMsg
+	if unknownMsg == "" {
+		unknownMsg = "Unexpected parameter %q."
+	}
+	missingMsg := f.Strict.MissingMsg
+	if missingMsg == "" {
+		missingMsg = "Missing parameter %q."
+	}
+	duplicateMsg := f.Strict.DuplicateMsg
+	if duplicateMsg == "" {
+		duplicateMsg = "Duplicated parameter %q."
+	}
+	// known maps known parameters to true if they are expected.
+	known := make(map[string]bool)
+	for _, name := range f.Strict.Allow {
+		known[name] = false
+	}
+	for _, name := range f.reserved {
+		known[name] = false
+	}
+	if f.AntiSpam != nil {
+		known[AntiSpamTimeParam] = false
+		known[f.AntiSpam.honeypot()] = false
+	}
+	addFields := func(fields []Field) {
+		for _, field := range fields {
+			expected := fieldKind(field) != "file" &&
+				field.Condition == nil && !f.omittable(field)
+			if composite, ok := field.Widget.(compositeWidget); ok {
+				for _, part := range composite.parts() {
+					known[field.Id+"."+part] = expected
+				}
+				continue
+			}
+			known[field.Id] = expected
+		}
+	}
+	addFields(f.Fields)
+	for _, formset := range f.Formsets {
+		known[formset.Id+".count"] = true
+		count := 0
+		if rows, err := f.formsetRows(formset); err == nil {
+			count = rows.Len()
+		}
+		param := values.Get(formset.Id + ".count")
+		if n, err := strconv.Atoi(param); err == nil && n >= 0 &&
+			n <= maxSliceLen {
+			count = n
+		}
+		for i := 0; i < count; i++ {
+			prefix := formset.rowPrefix(i)
+			known[prefix+"order"] = false
+			if formset.DeleteLabel != "" {
+				known[prefix+"delete"] = false
+			}
+			for _, field := range formset.Fields {
+				field.Id = prefix + field.Id
+				addFields([]Field{field})
+			}
+		}
+	}
+	ok := true
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		expected, isKnown := known[name]
+		params, submitted := values[name]
+		switch {
+		case !isKnown:
+			f.AddError("", fmt.Sprintf(unknownMsg, name))
+		case !submitted && expected:
+			f.AddError("", fmt.Sprintf(missingMsg, name))
+		case len(params) > 1:
+			f.AddError("", fmt.Sprintf(duplicateMsg, name))
+		default:
+			continue
+		}
+		ok = false
+	}
+	return ok
+}
+
+// omittable returns true if browsers omit the parameter of the given field
+// if nothing is selected.
+func (f *Form) omittable(field Field) bool {
+	if fieldKind(field) == "radio" {
+		return true
+	}
+	target, err := f.fieldType(field.Id)
+	return err == nil && target != nil &&
+		indirect(target).Kind() == reflect.Bool
+}
@@ -0,0 +1,113 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type TestStrictData struct {
+	Name    string
+	IsAdmin bool
+	File    string
+}
+
+func TestStrictMode(t *testing.T) {
+	tests := []struct {
+		Values url.Values
+		Strict *StrictMode
+		Valid  bool
+		Errors []string
+	}{
+		{url.Values{"Name": []string{"Foo"}, "IsAdmin": []string{"true"}},
+			nil, true, nil},
+		{url.Values{"Name": []string{"Foo"}}, &StrictMode{}, true, nil},
+		{url.Values{"Name": []string{"Foo"}, "IsAdmin": []string{"true"}},
+			&StrictMode{}, false, []string{`Unexpected parameter "IsAdmin".`}},
+		{url.Values{}, &StrictMode{}, false,
+			[]string{`Missing parameter "Name".`}},
+		{url.Values{"Name": []string{"Foo", "Bar"}}, &StrictMode{}, false,
+			[]string{`Duplicated parameter "Name".`}},
+		{url.Values{"Name": []string{"Foo"}, "csrf": []string{"token"}},
+			&StrictMode{Allow: []string{"csrf"}}, true, nil},
+		{url.Values{"IsAdmin": []string{"true"}, "Name": []string{"a", "b"}},
+			&StrictMode{UnknownMsg: "Unknown: %v", MissingMsg: "Missing: %v",
+				DuplicateMsg: "Twice: %v"}, false,
+			[]string{"Unknown: IsAdmin", "Twice: Name"}},
+	}
+	for i, test := range tests {
+		data := TestStrictData{}
+		form := NewForm(&data, []Field{
+			Field{Id: "Name", Label: "Name"},
+			Field{Id: "File", Label: "File", Widget: new(FileWidget)}})
+		form.Strict = test.Strict
+		if valid := form.Fill(test.Values); valid != test.Valid {
+			t.Errorf("Test %v: Fill returned %v, should be %v", i, valid,
+				test.Valid)
+		}
+		if !reflect.DeepEqual(form.RenderData().Errors, test.Errors) {
+			t.Errorf("Test %v: Errors are %v, should be %v", i,
+				form.RenderData().Errors, test.Errors)
+		}
+		if data.IsAdmin {
+			t.Errorf("Test %v: Unknown parameter has been bound", i)
+		}
+	}
+}
+
+func TestStrictModeOmittable(t *testing.T) {
+	data := struct {
+		Name      string
+		Subscribe bool
+		Terms     *bool
+		Color     string
+	}{Subscribe: true}
+	form := NewForm(&data, []Field{Field{Id: "Name"}, Field{Id: "Subscribe"},
+		Field{Id: "Terms"}, Field{Id: "Color", Widget: RadioWidget{
+			Options: []Option{{Value: "red", Text: "Red"}}}}})
+	form.Strict = &StrictMode{}
+	if !form.Fill(url.Values{"Name": {"Foo"}}) {
+		t.Errorf("Fill failed: %v", form.RenderData().Errors)
+	}
+	if form.Fill(url.Values{"Subscribe": {"true"}}) {
+		t.Errorf("Fill should fail without Name")
+	}
+}
+
+func TestStrictModeFormsets(t *testing.T) {
+	data := TestFormsetData{}
+	form := NewForm(&data, nil)
+	form.Formsets = []Formset{testFormset()}
+	form.Strict = &StrictMode{}
+	vals := url.Values{
+		"Addresses.count":    []string{"2"},
+		"Addresses.0.Street": []string{"a"},
+		"Addresses.0.Number": []string{"1"},
+		"Addresses.0.delete": []string{"true"},
+		"Addresses.1.Street": []string{"b"},
+		"Addresses.1.order":  []string{"0"},
+		"Addresses.2.Street": []string{"c"}}
+	form.Fill(vals)
+	expected := []string{`Missing parameter "Addresses.1.Number".`,
+		`Unexpected parameter "Addresses.2.Street".`}
+	if !reflect.DeepEqual(form.RenderData().Errors, expected) {
+		t.Errorf("Errors are %v, should be %v", form.RenderData().Errors,
+			expected)
+	}
+}
@@ -0,0 +1,676 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed widgets/*.html
+var widgetFS embed.FS
+
+var (
+	// defaultWidgetTemplates contains the embedded widget templates. It never
+	// gets executed so that it can be cloned by LoadWidgetTemplates.
+	defaultWidgetTemplates = template.Must(
+		template.ParseFS(widgetFS, "widgets/*.html"))
+	// widgetTemplates contains the widget templates in use.
+	widgetTemplates      = template.Must(defaultWidgetTemplates.Clone())
+	widgetTemplatesMutex sync.RWMutex
+)
+
+// WidgetData is the data passed to widget templates.
+type WidgetData struct {
+	// Id is the field's Id. It's used as id and name of the input.
+	Id string
+	// Value is the field's value formatted for the input.
+	Value string
+	// Widget is the widget to be rendered.
+	Widget Widget
+	// Options contains the options of selection widgets.
+	Options []OptionData
+	// Groups contains the options of selection widgets grouped by their
+	// Group. Consecutive options of the same group form a single group.
+	Groups []OptionGroupData
+	// Parts contains the data of the inputs of composite widgets, e.g. the
+	// start and end of a DateRangeWidget.
+	Parts []WidgetData
+	// Attrs contains the sanitized additional attributes of the input.
+	Attrs template.HTMLAttr
+}
+
+// OptionData is the data of an option passed to widget templates.
+type OptionData struct {
+	Option
+	// Selected is true iff the option matches the field's value.
+	Selected bool
+	// HTMLAttrs contains the sanitized Attrs of the option.
+	HTMLAttrs template.HTMLAttr
+}
+
+// OptionGroupData is the data of a group of options passed to widget
+// templates.
+type OptionGroupData struct {
+	// Label is the Group of the options. It's empty for options without a
+	// group.
+	Label   string
+	Options []OptionData
+}
+
+// LoadWidgetTemplates replaces the templates of the built-in widgets with
+// the templates defined in the files matching the given patterns.
+//
+// Each widget kind has its own template named like the kind, e.g. "text" or
+// "select", which gets executed with a WidgetData value. Widget kinds not
+// defined in the given files keep their default template. Templates loaded
+// by previous calls get discarded.
+func LoadWidgetTemplates(patterns ...string) error {
+	return loadWidgetTemplates(func(t *template.Template, pattern string) (
+		*template.Template, error) {
+		return t.ParseGlob(pattern)
+	}, patterns)
+}
+
+// LoadWidgetTemplatesFS is like LoadWidgetTemplates but reads the templates
+// from the given file system.
+func LoadWidgetTemplatesFS(fsys fs.FS, patterns ...string) error {
+	return loadWidgetTemplates(func(t *template.Template, pattern string) (
+		*template.Template, error) {
+		return t.ParseFS(fsys, pattern)
+	}, patterns)
+}
+
+// loadWidgetTemplates parses the given patterns into a copy of the default
+// widget templates using the given parse function and puts the result in
+// use.
+func loadWidgetTemplates(parse func(*template.Template, string) (
+	*template.Template, error), patterns []string) error {
+	templates, err := defaultWidgetTemplates.Clone()
+	if err != nil {
+		return fmt.Errorf("form: Could not clone widget templates: %v", err)
+	}
+	for _, pattern := range patterns {
+		templates, err = parse(templates, pattern)
+		if err != nil {
+			return fmt.Errorf("form: Could not load widget templates: %v", err)
+		}
+	}
+	widgetTemplatesMutex.Lock()
+	widgetTemplates = templates
+	widgetTemplatesMutex.Unlock()
+	return nil
+}
+
+// renderWidget renders the widget template of the given kind.
+func renderWidget(kind string, data WidgetData) template.HTML {
+	widgetTemplatesMutex.RLock()
+	templates := widgetTemplates
+	widgetTemplatesMutex.RUnlock()
+	var out bytes.Buffer
+	if err := templates.ExecuteTemplate(&out, kind, data); err != nil {
+		return template.HTML(html.EscapeString(fmt.Sprintf(
+			"form: Could not render widget %q: %v", data.Id, err)))
+	}
+	return template.HTML(out.String())
+}
+
+// widgetKinds maps the built-in widget types to their kinds.
+var widgetKinds = map[reflect.Type]string{
+	reflect.TypeOf(DateTimeWidget(0)):  "datetime",
+	reflect.TypeOf(DateWidget(0)):      "date",
+	reflect.TypeOf(TimeWidget(0)):      "time",
+	reflect.TypeOf(Text(0)):            "text",
+	reflect.TypeOf(AlohaEditor(0)):     "editor",
+	reflect.TypeOf(RichTextWidget{}):   "richtext",
+	reflect.TypeOf(TextArea(0)):        "textarea",
+	reflect.TypeOf(SelectWidget{}):     "select",
+	reflect.TypeOf(RadioWidget{}):      "radio",
+	reflect.TypeOf(HiddenWidget(0)):    "hidden",
+	reflect.TypeOf(PasswordWidget{}):   "password",
+	reflect.TypeOf(FileWidget(0)):      "file",
+	reflect.TypeOf(CurrencyWidget{}):   "currency",
+	reflect.TypeOf(DurationWidget(0)):  "duration",
+	reflect.TypeOf(MonthWidget(0)):     "month",
+	reflect.TypeOf(WeekWidget(0)):      "week",
+	reflect.TypeOf(DateRangeWidget(0)): "daterange",
+	reflect.TypeOf(NumberWidget{}):     "number",
+	reflect.TypeOf(RangeWidget{}):      "range",
+	reflect.TypeOf(EmailWidget(0)):     "email",
+	reflect.TypeOf(URLWidget(0)):       "url",
+	reflect.TypeOf(TelWidget(0)):       "tel",
+	reflect.TypeOf(ColorWidget(0)):     "color",
+}
+
+// widgetKind returns the kind of the given widget.
+//
+// The kind of widgets which are not built-in is the lower cased name of
+// their type.
+func widgetKind(widget Widget) string {
+	widgetType := reflect.TypeOf(widget)
+	if widgetType.Kind() == reflect.Ptr {
+		widgetType = widgetType.Elem()
+	}
+	if kind, ok := widgetKinds[widgetType]; ok {
+		return kind
+	}
+	return strings.ToLower(widgetType.Name())
+}
+
+// formatValue formats the given value for an input. Pointers without a
+// String method are dereferenced, nil pointers are formatted as empty
+// string.
+func formatValue(value interface{}) string {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		if _, ok := value.(fmt.Stringer); !ok {
+			return formatValue(v.Elem().Interface())
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// formatTime formats the given time or time pointer using the given layout.
+// Nil pointers and zero times are formatted as empty string.
+//
+// Other values are formatted using formatValue.
+func formatTime(value interface{}, layout string) string {
+	switch obj := value.(type) {
+	case time.Time:
+		if obj.IsZero() {
+			return ""
+		}
+		return obj.Format(layout)
+	case *time.Time:
+		if obj == nil {
+			return ""
+		}
+		return formatTime(*obj, layout)
+	}
+	return formatValue(value)
+}
+
+// dateTimeLayout returns the layout of datetime-local inputs for the given
+// time or time pointer. Seconds are only included if needed.
+func dateTimeLayout(value interface{}) string {
+	switch obj := value.(type) {
+	case *time.Time:
+		if obj != nil {
+			return dateTimeLayout(*obj)
+		}
+	case time.Time:
+		if obj.Second() != 0 || obj.Nanosecond() != 0 {
+			return "2006-01-02T15:04:05"
+		}
+	}
+	return "2006-01-02T15:04"
+}
+
+// inLocation returns the given time or time pointer in the given location.
+//
+// Other values are returned unchanged.
+func inLocation(value interface{}, loc *time.Location) interface{} {
+	switch obj := value.(type) {
+	case time.Time:
+		return obj.In(loc)
+	case *time.Time:
+		if obj != nil {
+			converted := obj.In(loc)
+			return &converted
+		}
+	}
+	return value
+}
+
+// DateTimeWidget renders a datetime-local input for date and time.
+//
+// The local time of the value is rendered. Forms render it in their
+// Location, see Form.Location.
+type DateTimeWidget int
+
+func (t DateTimeWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("datetime", WidgetData{Id: field, Widget: t,
+		Value: formatTime(value, dateTimeLayout(value))})
+}
+
+// DateWidget renders a date input.
+type DateWidget int
+
+func (t DateWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("date", WidgetData{Id: field, Widget: t,
+		Value: formatTime(value, "2006-01-02")})
+}
+
+// TimeWidget renders a time input.
+type TimeWidget int
+
+func (t TimeWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("time", WidgetData{Id: field, Widget: t,
+		Value: formatTime(value, "15:04:05")})
+}
+
+// Text renders a text input.
+type Text int
+
+func (t Text) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("text", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// AlohaEditor renders a textarea to be used with the Aloha editor.
+//
+// Submitted HTML is sanitized by Fill using DefaultPolicy().
+//
+// Deprecated: Use RichTextWidget.
+type AlohaEditor int
+
+func (t AlohaEditor) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("editor", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+func (t AlohaEditor) sanitize(value string) string {
+	return defaultPolicy.Sanitize(value)
+}
+
+// TextArea renders a textarea.
+type TextArea int
+
+func (t TextArea) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("textarea", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// Option of a select widget.
+type Option struct {
+	Value, Text string
+	// Group optionally is the label of the group of the option.
+	Group string
+	// Disabled options can't be selected. They are rejected by OneOf and by
+	// Fill.
+	Disabled bool
+	// Attrs contains additional attributes of the option, e.g. data
+	// attributes. Event handlers, invalid names and attributes set by the
+	// widgets are dropped.
+	Attrs map[string]string
+}
+
+// SelectWidget renders a selection field.
+type SelectWidget struct {
+	Options []Option
+	// Provider provides the options instead of Options if not nil.
+	Provider OptionProvider
+	// InvalidMsg is the error for values which are not among the enabled
+	// options. Defaults to "Invalid choice." if empty.
+	InvalidMsg string
+	// Placeholder optionally is the text of an additional first option with
+	// an empty value, e.g. "Choose one". Required rejects it.
+	Placeholder string
+}
+
+func (t SelectWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("select", optionsWidgetData(field, t, t.options(),
+		value))
+}
+
+func (t SelectWidget) options() []Option {
+	if t.Provider == nil {
+		return t.Options
+	}
+	options, _ := t.Provider.Options(context.Background())
+	return options
+}
+
+func (t SelectWidget) choices() (OptionProvider, string) {
+	if t.Provider == nil {
+		return StaticOptions(t.Options), t.InvalidMsg
+	}
+	return t.Provider, t.InvalidMsg
+}
+
+func (t SelectWidget) withOptions(options []Option) Widget {
+	t.Options, t.Provider = options, nil
+	return t
+}
+
+// RadioWidget renders a group of radio buttons.
+type RadioWidget struct {
+	Options []Option
+	// Provider provides the options instead of Options if not nil.
+	Provider OptionProvider
+	// InvalidMsg is the error for values which are not among the enabled
+	// options. Defaults to "Invalid choice." if empty.
+	InvalidMsg string
+}
+
+func (t RadioWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("radio", optionsWidgetData(field, t, t.options(),
+		value))
+}
+
+func (t RadioWidget) options() []Option {
+	return SelectWidget{Options: t.Options, Provider: t.Provider}.options()
+}
+
+func (t RadioWidget) choices() (OptionProvider, string) {
+	return SelectWidget{Options: t.Options, Provider: t.Provider,
+		InvalidMsg: t.InvalidMsg}.choices()
+}
+
+func (t RadioWidget) withOptions(options []Option) Widget {
+	t.Options, t.Provider = options, nil
+	return t
+}
+
+// optionsWidgetData returns the template data of a widget with the given
+// options.
+func optionsWidgetData(field string, widget Widget, options []Option,
+	value interface{}) WidgetData {
+	data := WidgetData{Id: field, Widget: widget, Value: formatValue(value),
+		Options: make([]OptionData, 0, len(options))}
+	for i, option := range options {
+		optionData := OptionData{Option: option,
+			Selected: option.Value == data.Value,
+			HTMLAttrs: htmlAttrs(option.Attrs,
+				reservedOptionAttrs)}
+		data.Options = append(data.Options, optionData)
+		if i == 0 || option.Group != options[i-1].Group {
+			data.Groups = append(data.Groups,
+				OptionGroupData{Label: option.Group})
+		}
+		group := &data.Groups[len(data.Groups)-1]
+		group.Options = append(group.Options, optionData)
+	}
+	return data
+}
+
+// attrNameRegexp matches valid attribute names.
+var attrNameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.:-]*$`)
+
+// reservedOptionAttrs contains the attributes set by the widgets.
+var reservedOptionAttrs = map[string]bool{
+	"id": true, "name": true, "type": true, "value": true, "selected": true,
+	"checked": true, "disabled": true}
+
+// htmlAttrs returns the given additional attributes of an input or option
+// as html.
+//
+// Invalid names, event handlers and the given reserved attributes are
+// dropped.
+func htmlAttrs(attrs map[string]string,
+	reserved map[string]bool) template.HTMLAttr {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		lower := strings.ToLower(name)
+		if attrNameRegexp.MatchString(name) && !strings.HasPrefix(lower, "on") &&
+			!reserved[lower] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var ret bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&ret, ` %v="%v"`, name,
+			template.HTMLEscapeString(attrs[name]))
+	}
+	return template.HTMLAttr(ret.String())
+}
+
+// DurationWidget renders a text input for a time.Duration, e.g. "1h30m".
+type DurationWidget int
+
+func (t DurationWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("duration", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// MonthWidget renders a month input for a time.Time.
+type MonthWidget int
+
+func (t MonthWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("month", WidgetData{Id: field, Widget: t,
+		Value: formatTime(value, "2006-01")})
+}
+
+// WeekWidget renders a week input for a time.Time.
+type WeekWidget int
+
+func (t WeekWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("week", WidgetData{Id: field, Widget: t,
+		Value: formatWeek(value)})
+}
+
+// formatWeek formats the ISO 8601 week of the given time or time pointer,
+// e.g. "2026-W42".
+func formatWeek(value interface{}) string {
+	if ptr, ok := value.(*time.Time); ok && ptr != nil {
+		value = *ptr
+	}
+	date, ok := value.(time.Time)
+	if !ok {
+		return formatTime(value, "")
+	}
+	if date.IsZero() {
+		return ""
+	}
+	year, week := date.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// NumberWidget renders a number input.
+//
+// Min, Max and Step are rendered as attributes if not empty, e.g. "0",
+// "100" and "0.5". Step may be "any". Fill rejects values violating them,
+// steps are counted from Min or zero. Use a pointer field to accept empty
+// inputs.
+type NumberWidget struct {
+	Min, Max, Step string
+	// InvalidMsg is the error for values violating Min, Max or Step.
+	// Defaults to "Invalid number." if empty.
+	InvalidMsg string
+}
+
+func (t NumberWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("number", WidgetData{Id: field, Widget: t,
+		Value: formatNumber(value)})
+}
+
+// RangeWidget renders a range input, i.e. a slider.
+//
+// Min, Max, Step and InvalidMsg are used like those of NumberWidget.
+type RangeWidget struct {
+	Min, Max, Step string
+	InvalidMsg     string
+}
+
+func (t RangeWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("range", WidgetData{Id: field, Widget: t,
+		Value: formatNumber(value)})
+}
+
+func (t NumberWidget) bounds() (min, max, step, msg string) {
+	return t.Min, t.Max, t.Step, t.InvalidMsg
+}
+
+func (t RangeWidget) bounds() (min, max, step, msg string) {
+	return t.Min, t.Max, t.Step, t.InvalidMsg
+}
+
+// boundedWidget is implemented by widgets restricting the range of their
+// numeric values.
+type boundedWidget interface {
+	Widget
+	// bounds returns the widget's Min, Max and Step and the error message
+	// for values violating them.
+	bounds() (min, max, step, msg string)
+}
+
+// validateBounds checks the given value of the given field against the
+// Min, Max and Step of the field's widget.
+//
+// Bounds which are empty or no numbers are ignored, as are nil pointers and
+// values which are no numbers.
+func validateBounds(field Field, value interface{}) []string {
+	widget, ok := field.Widget.(boundedWidget)
+	if !ok {
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	var number float64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		number = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		number = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		number = v.Float()
+	default:
+		return nil
+	}
+	min, max, step, msg := widget.bounds()
+	if msg == "" {
+		msg = "Invalid number."
+	}
+	base := 0.0
+	if bound, err := strconv.ParseFloat(min, 64); err == nil {
+		if number < bound {
+			return []string{msg}
+		}
+		base = bound
+	}
+	bound, err := strconv.ParseFloat(max, 64)
+	if err == nil && number > bound {
+		return []string{msg}
+	}
+	if step, err := strconv.ParseFloat(step, 64); err == nil && step > 0 {
+		// Allow for rounding errors of fractional steps.
+		steps := (number - base) / step
+		tolerance := 1e-9 * math.Max(1, math.Abs(steps))
+		if math.Abs(steps-math.Round(steps)) > tolerance {
+			return []string{msg}
+		}
+	}
+	return nil
+}
+
+// formatNumber formats the given number for number inputs. Floats are
+// formatted without exponent.
+//
+// Other values are formatted using formatValue.
+func formatNumber(value interface{}) string {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	}
+	if v.IsValid() {
+		value = v.Interface()
+	}
+	return formatValue(value)
+}
+
+// EmailWidget renders an email input.
+type EmailWidget int
+
+func (t EmailWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("email", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// URLWidget renders a url input.
+type URLWidget int
+
+func (t URLWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("url", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// TelWidget renders an input for telephone numbers.
+type TelWidget int
+
+func (t TelWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("tel", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// ColorWidget renders a color input. Values are colors in hexadecimal
+// notation, e.g. "#ff0000".
+type ColorWidget int
+
+func (t ColorWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("color", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// HiddenWidget renders a hidden input field.
+type HiddenWidget int
+
+func (t HiddenWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("hidden", WidgetData{Id: field, Widget: t,
+		Value: formatValue(value)})
+}
+
+// PasswordWidget renders a password field. It never renders the field's
+// value.
+//
+// PasswordWidget used to be an int type, use PasswordWidget{} or
+// new(PasswordWidget) instead of PasswordWidget(0).
+type PasswordWidget struct {
+	// Autocomplete optionally is the autocomplete attribute of the input,
+	// e.g. "new-password" or "current-password", so that password managers
+	// can suggest new or fill in stored passwords.
+	Autocomplete string
+}
+
+func (t PasswordWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("password", WidgetData{Id: field, Widget: t})
+}
+
+// FileWidget renders a file upload field.
+//
+// The field must be bound to a *multipart.FileHeader or, for multiple
+// files, a []*multipart.FileHeader. Files are bound by FillMultipart only.
+type FileWidget int
+
+func (t FileWidget) HTML(field string, value interface{}) template.HTML {
+	return renderWidget("file", WidgetData{Id: field, Widget: t})
+}
@@ -0,0 +1,285 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWidgetEscaping(t *testing.T) {
+	tests := []struct {
+		Widget   Widget
+		Value    interface{}
+		Expected string
+	}{
+		{new(Text), `"><script>`,
+			`<input id="foo" type="text" name="foo" value="&#34;&gt;&lt;script&gt;"/>`},
+		{new(HiddenWidget), `a"b`,
+			`<input id="foo" type="hidden" name="foo" value="a&#34;b"/>`},
+		{new(TextArea), `</textarea>`,
+			`<textarea id="foo" name="foo">&lt;/textarea&gt;</textarea>`},
+		{new(DateWidget), "not a date",
+			`<input id="foo" type="date" name="foo" value="not a date"/>`},
+		{SelectWidget{Options: []Option{Option{Value: "<a>", Text: "<b>"}}}, 1,
+			`<select id="foo" name="foo">
+<option value="&lt;a&gt;">&lt;b&gt;</option>
+</select>`},
+		{RadioWidget{Options: []Option{{Value: "a", Text: "A"},
+			{Value: "<b>", Text: "B"}}}, "<b>",
+			`<label><input id="foo-0" type="radio" name="foo" value="a"/> A</label>
+<label><input id="foo-1" type="radio" name="foo" value="&lt;b&gt;" checked/> B</label>
+`},
+	}
+	for i, test := range tests {
+		ret := test.Widget.HTML("foo", test.Value)
+		if string(ret) != test.Expected {
+			t.Errorf("Test %v: HTML(\"foo\", %q) = %q, should be %q", i,
+				test.Value, ret, test.Expected)
+		}
+	}
+}
+
+func TestLoadWidgetTemplates(t *testing.T) {
+	defer LoadWidgetTemplates()
+	dir, err := ioutil.TempDir("", "form")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "widgets.html"), []byte(
+		`{{define "text"}}<input class="custom" name="{{.Id}}">{{end}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadWidgetTemplates(filepath.Join(dir, "*.html")); err != nil {
+		t.Fatalf("LoadWidgetTemplates failed: %v", err)
+	}
+	if ret := new(Text).HTML("foo", ""); ret !=
+		`<input class="custom" name="foo">` {
+		t.Errorf("Text widget should use loaded template, got %q", ret)
+	}
+	if ret := new(HiddenWidget).HTML("foo", ""); ret !=
+		`<input id="foo" type="hidden" name="foo" value=""/>` {
+		t.Errorf("HiddenWidget should use default template, got %q", ret)
+	}
+
+	fsys := fstest.MapFS{"password.html": &fstest.MapFile{Data: []byte(
+		`{{define "password"}}<input type="password" name="{{.Id}}" class="pw">{{end}}`)}}
+	if err := LoadWidgetTemplatesFS(fsys, "*.html"); err != nil {
+		t.Fatalf("LoadWidgetTemplatesFS failed: %v", err)
+	}
+	if ret := new(PasswordWidget).HTML("foo", ""); ret !=
+		`<input type="password" name="foo" class="pw">` {
+		t.Errorf("PasswordWidget should use loaded template, got %q", ret)
+	}
+	if ret := new(Text).HTML("foo", ""); ret !=
+		`<input id="foo" type="text" name="foo" value=""/>` {
+		t.Errorf("Previously loaded templates should be discarded, got %q", ret)
+	}
+	if err := LoadWidgetTemplates(filepath.Join(dir, "missing-*.html")); err == nil {
+		t.Errorf("LoadWidgetTemplates should fail for patterns without matches")
+	}
+}
+
+func TestPeriodWidgets(t *testing.T) {
+	date := time.Date(2027, 1, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Widget   Widget
+		Value    interface{}
+		Expected string
+	}{
+		{new(MonthWidget), date,
+			`<input id="foo" type="month" name="foo" value="2027-01"/>`},
+		{new(MonthWidget), (*time.Time)(nil),
+			`<input id="foo" type="month" name="foo" value=""/>`},
+		{new(WeekWidget), &date,
+			`<input id="foo" type="week" name="foo" value="2026-W53"/>`},
+		{new(WeekWidget), time.Time{},
+			`<input id="foo" type="week" name="foo" value=""/>`},
+		{new(DurationWidget), 90 * time.Minute,
+			`<input id="foo" type="text" name="foo" value="1h30m0s"/>`},
+	}
+	for i, test := range tests {
+		if ret := test.Widget.HTML("foo", test.Value); string(ret) !=
+			test.Expected {
+			t.Errorf("Test %v: HTML(\"foo\", %v) = %v, should be %v", i,
+				test.Value, ret, test.Expected)
+		}
+	}
+}
+
+func TestNumberBounds(t *testing.T) {
+	tests := []struct {
+		Widget Widget
+		Value  string
+		Valid  bool
+	}{
+		{NumberWidget{Min: "1", Max: "10"}, "1", true},
+		{NumberWidget{Min: "1", Max: "10"}, "10", true},
+		{NumberWidget{Min: "1", Max: "10"}, "0", false},
+		{NumberWidget{Min: "1", Max: "10"}, "11", false},
+		{NumberWidget{Min: "1", Max: "10"}, "", true},
+		{NumberWidget{Step: "0.1"}, "0.3", true},
+		{NumberWidget{Step: "0.1"}, "0.35", false},
+		{NumberWidget{Min: "0.5", Step: "2"}, "4.5", true},
+		{NumberWidget{Min: "0.5", Step: "2"}, "4", false},
+		{NumberWidget{Step: "any"}, "0.123", true},
+		{RangeWidget{Min: "-5", Max: "5", Step: "0.5"}, "-2.5", true},
+		{RangeWidget{Min: "-5", Max: "5", Step: "0.5"}, "-5.5", false},
+	}
+	for i, test := range tests {
+		data := struct{ Number *float64 }{}
+		form := NewForm(&data, []Field{Field{Id: "Number",
+			Widget: test.Widget}})
+		if valid := form.Fill(url.Values{"Number": {test.Value}}); valid !=
+			test.Valid {
+			t.Errorf("Test %v: Fill(%q) returned %v, errors: %v", i,
+				test.Value, valid, form.errors)
+		}
+	}
+	data := struct{ Count int }{}
+	form := NewForm(&data, []Field{Field{Id: "Count",
+		Widget: NumberWidget{Max: "3", InvalidMsg: "Too many!"}}})
+	if form.Fill(url.Values{"Count": {"4"}}) ||
+		strings.Join(form.errors["Count"], "") != "Too many!" {
+		t.Errorf("Fill should fail with Too many!, got %v", form.errors)
+	}
+}
+
+func TestInputWidgets(t *testing.T) {
+	small := float32(0.00001)
+	tests := []struct {
+		Widget   Widget
+		Value    interface{}
+		Expected string
+	}{
+		{NumberWidget{Min: "0", Step: "any"}, 1e21,
+			`<input id="foo" type="number" name="foo" ` +
+				`value="1000000000000000000000" min="0" step="any"/>`},
+		{new(NumberWidget), &small,
+			`<input id="foo" type="number" name="foo" value="0.00001"/>`},
+		{new(NumberWidget), (*int)(nil),
+			`<input id="foo" type="number" name="foo" value=""/>`},
+		{RangeWidget{Min: "-5", Max: "5", Step: "0.5"}, -2.5,
+			`<input id="foo" type="range" name="foo" value="-2.5" min="-5" ` +
+				`max="5" step="0.5"/>`},
+		{new(EmailWidget), "a@example.com",
+			`<input id="foo" type="email" name="foo" value="a@example.com"/>`},
+		{new(URLWidget), "https://example.com/?a=1&b=2",
+			`<input id="foo" type="url" name="foo" ` +
+				`value="https://example.com/?a=1&amp;b=2"/>`},
+		{new(TelWidget), "+49 30 1234",
+			`<input id="foo" type="tel" name="foo" value="&#43;49 30 1234"/>`},
+		{new(ColorWidget), "#ff0000",
+			`<input id="foo" type="color" name="foo" value="#ff0000"/>`},
+	}
+	for i, test := range tests {
+		if ret := test.Widget.HTML("foo", test.Value); string(ret) !=
+			test.Expected {
+			t.Errorf("Test %v: HTML(\"foo\", %v) = %v, should be %v", i,
+				test.Value, ret, test.Expected)
+		}
+	}
+}
+
+func TestSelectWidgetGroups(t *testing.T) {
+	widget := SelectWidget{Placeholder: "Choose one", Options: []Option{
+		{Value: "de", Text: "Germany", Group: "Europe",
+			Attrs: map[string]string{"data-code": `"49"`, "onclick": "evil()",
+				"value": "other", "bad name": "x"}},
+		{Value: "fr", Text: "France", Group: "Europe", Disabled: true},
+		{Value: "us", Text: "USA", Group: "America"},
+		{Value: "xx", Text: "Other"}}}
+	expected := `<select id="foo" name="foo">
+<option value="" selected>Choose one</option>
+<optgroup label="Europe">
+<option value="de" data-code="&#34;49&#34;">Germany</option>
+<option value="fr" disabled>France</option>
+</optgroup>
+<optgroup label="America">
+<option value="us">USA</option>
+</optgroup>
+<option value="xx">Other</option>
+</select>`
+	if ret := widget.HTML("foo", ""); string(ret) != expected {
+		t.Errorf("HTML(\"foo\", \"\") = %v, should be %v", ret, expected)
+	}
+	if ret := widget.HTML("foo", "us"); !strings.Contains(string(ret),
+		`<option value="">Choose one</option>`) ||
+		!strings.Contains(string(ret), `<option value="us" selected>`) {
+		t.Errorf("HTML(\"foo\", \"us\") = %v", ret)
+	}
+}
+
+func TestSelectWidgetPlaceholder(t *testing.T) {
+	data := struct{ Country *string }{}
+	provider := StaticOptions{{Value: "de", Text: "Germany"},
+		{Value: "fr", Text: "France", Disabled: true}}
+	form := NewForm(&data, []Field{Field{Id: "Country",
+		Validator: Required("Req!"),
+		Widget:    SelectWidget{Placeholder: "Choose", Provider: provider}}})
+	tests := []struct {
+		Value string
+		Valid bool
+	}{{"de", true}, {"", false}, {"fr", false}, {"it", false}}
+	for i, test := range tests {
+		if valid := form.Fill(url.Values{"Country": {test.Value}}); valid !=
+			test.Valid {
+			t.Errorf("Test %v: Fill(%q) returned %v, should be %v", i,
+				test.Value, valid, test.Valid)
+		}
+	}
+	form.Fill(url.Values{"Country": {""}})
+	if data.Country != nil {
+		t.Errorf("Placeholder should unset Country, got %q", *data.Country)
+	}
+}
+
+func TestPointerChoice(t *testing.T) {
+	data := struct{ Choice, Other *string }{}
+	options := []Option{{Value: "a", Text: "A"}, {Value: "b", Text: "B"}}
+	form := NewForm(&data, []Field{
+		Field{Id: "Choice", Widget: SelectWidget{Options: options}},
+		Field{Id: "Other", Widget: RadioWidget{Options: options}}})
+	if input := form.RenderData().Fields[0].Input; strings.Contains(
+		string(input), "selected") {
+		t.Errorf("No option should be selected, got %v", input)
+	}
+	if !form.Fill(url.Values{"Choice": {"b"}, "Other": {"b"}}) {
+		t.Fatalf("Fill failed: %v", form.errors)
+	}
+	fields := form.RenderData().Fields
+	if input := fields[0].Input; !strings.Contains(string(input),
+		`<option value="b" selected>B</option>`) {
+		t.Errorf("Option b should be selected, got %v", input)
+	}
+	if input := fields[1].Input; !strings.Contains(string(input),
+		`value="b" checked`) {
+		t.Errorf("Option b should be checked, got %v", input)
+	}
+	if ret := new(Text).HTML("foo", data.Choice); !strings.Contains(
+		string(ret), `value="b"`) {
+		t.Errorf("Text should render the pointed to value, got %v", ret)
+	}
+}
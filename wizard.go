@@ -0,0 +1,309 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Parameters used by wizards in addition to the fields of the steps.
+const (
+	// WizardStepParam contains the index of the submitted step.
+	WizardStepParam = "wizard.step"
+	// WizardBackParam requests to go back to the previous step if
+	// submitted, e.g. by a button:
+	//
+	//	<button type="submit" name="wizard.back" value="1">Back</button>
+	WizardBackParam = "wizard.back"
+	// WizardStateParam contains the state of wizards using a SignedStore.
+	WizardStateParam = "wizard.state"
+)
+
+// now returns the current time. It may be replaced by tests.
+var now = time.Now
+
+// WizardStep is a single step of a Wizard.
+type WizardStep struct {
+	// Spec defines the form of the step.
+	Spec *FormSpec
+	// Skip optionally decides to skip the step depending on the data
+	// accumulated by the previous steps.
+	Skip func(data interface{}) bool
+}
+
+// WizardStore saves the state of a wizard between requests.
+type WizardStore interface {
+	// Load returns the state for a request with the given submitted values.
+	Load(values url.Values) ([]byte, error)
+	// Save saves the state and returns HTML to be included in the form of
+	// the current step.
+	Save(state []byte) (template.HTML, error)
+}
+
+// SignedStore stores the state of a wizard in a hidden field signed with
+// HMAC-SHA256.
+//
+// The state is visible to the user, so it should not contain secrets.
+type SignedStore struct {
+	// Key is the secret key used to sign the state. It must not be empty.
+	Key []byte
+	// Name identifies the wizard, e.g. "signup". States saved by stores
+	// with other names are rejected, so that wizards sharing the Key can't
+	// be fed each other's states.
+	Name string
+	// MaxAge limits the time between two steps if not zero.
+	MaxAge time.Duration
+}
+
+// Load verifies and returns the state submitted in the WizardStateParam
+// parameter.
+func (s SignedStore) Load(values url.Values) ([]byte, error) {
+	if len(s.Key) == 0 {
+		return nil, fmt.Errorf("form: SignedStore has no Key")
+	}
+	payload, err := verify(s.Key, s.purpose(),
+		values.Get(WizardStateParam))
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("form: Malformed wizard state")
+	}
+	saved := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	if s.MaxAge != 0 && now().Sub(saved) > s.MaxAge {
+		return nil, fmt.Errorf("form: Wizard state expired")
+	}
+	return payload[8:], nil
+}
+
+// purpose returns the purpose of the store's signatures, see sign.
+func (s SignedStore) purpose() string {
+	return "wizard:" + s.Name
+}
+
+// Save returns a hidden input containing the signed state.
+func (s SignedStore) Save(state []byte) (template.HTML, error) {
+	if len(s.Key) == 0 {
+		return "", fmt.Errorf("form: SignedStore has no Key")
+	}
+	payload := make([]byte, 8, 8+len(state))
+	binary.BigEndian.PutUint64(payload, uint64(now().Unix()))
+	payload = append(payload, state...)
+	return template.HTML(fmt.Sprintf(
+		`<input type="hidden" name="%v" value="%v"/>`, WizardStateParam,
+		template.HTMLEscapeString(sign(s.Key, s.purpose(), payload)))), nil
+}
+
+// Session is the interface of session implementations used by SessionStore.
+type Session interface {
+	// Get returns the value of the given key or nil if there is none.
+	Get(key string) []byte
+	// Set sets the value of the given key.
+	Set(key string, value []byte)
+}
+
+// SessionStore stores the state of a wizard in a session.
+type SessionStore struct {
+	// Session is the session of the current request.
+	Session Session
+	// Key is the session key of the state. Defaults to "wizard".
+	Key string
+}
+
+func (s SessionStore) key() string {
+	if s.Key == "" {
+		return "wizard"
+	}
+	return s.Key
+}
+
+// Load returns the state stored in the session.
+func (s SessionStore) Load(values url.Values) ([]byte, error) {
+	state := s.Session.Get(s.key())
+	if state == nil {
+		return nil, fmt.Errorf("form: Missing wizard state")
+	}
+	return state, nil
+}
+
+// Save stores the state in the session.
+func (s SessionStore) Save(state []byte) (template.HTML, error) {
+	s.Session.Set(s.key(), state)
+	return "", nil
+}
+
+// Wizard composes several forms into steps.
+//
+// Each step is validated on submit. The data accumulated by the steps is
+// carried between requests by the Store.
+type Wizard struct {
+	Steps []WizardStep
+	Store WizardStore
+}
+
+// WizardPage is the result of processing a request to a wizard.
+type WizardPage struct {
+	// Step is the index of the current step.
+	Step int
+	// Form is the form of the current step. It's nil if Done.
+	Form *Form
+	// Back is true if there is a previous step to go back to.
+	Back bool
+	// Done is true if the last step has been submitted successfully. The
+	// data contains the values of all steps then.
+	Done bool
+}
+
+// wizardState is the state of a wizard saved between requests.
+type wizardState struct {
+	Step int
+	Data json.RawMessage
+}
+
+// Process processes a request to the wizard and returns the page to be
+// presented to the user.
+//
+// data must be a pointer to a struct which is used for all steps. It must
+// be serializable by encoding/json. The wizard starts at the first step if
+// values are nil or don't contain the WizardStepParam parameter. Otherwise,
+// the data is restored from the store and the submitted step is filled and
+// validated. The current step is presented again if it's not valid, the
+// saved state keeps the data of the previous steps then.
+//
+// An error is returned if the state could not be restored, e.g. if it has
+// been tampered with. The caller may restart the wizard in this case.
+func (w *Wizard) Process(data interface{}, values url.Values) (
+	*WizardPage, error) {
+	if _, ok := values[WizardStepParam]; !ok {
+		return w.page(data, w.next(data, -1), nil, nil)
+	}
+	raw, err := w.Store.Load(values)
+	if err != nil {
+		return nil, err
+	}
+	var state wizardState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, fmt.Errorf("form: Malformed wizard state: %v", err)
+	}
+	if state.Step < 0 || state.Step >= len(w.Steps) {
+		return nil, fmt.Errorf("form: Invalid wizard step %v", state.Step)
+	}
+	if err := json.Unmarshal(state.Data, data); err != nil {
+		return nil, fmt.Errorf("form: Malformed wizard state: %v", err)
+	}
+	step := state.Step
+	submitted, err := strconv.Atoi(values.Get(WizardStepParam))
+	switch {
+	case err != nil || submitted != step:
+		// Stale submission, e.g. after using the browser's back button.
+	case values.Get(WizardBackParam) != "":
+		if prev := w.prev(data, step); prev >= 0 {
+			step = prev
+		}
+	default:
+		form, err := w.form(data, step)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := form.TryFill(values)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// Keep the data of the previous steps, the submitted values
+			// have not been validated.
+			return w.page(data, step, form, state.Data)
+		}
+		step = w.next(data, step)
+	}
+	return w.page(data, step, nil, nil)
+}
+
+// next returns the index of the first step after the given one which is
+// not skipped, or len(w.Steps) if there is none.
+func (w *Wizard) next(data interface{}, step int) int {
+	for step++; step < len(w.Steps); step++ {
+		if skip := w.Steps[step].Skip; skip == nil || !skip(data) {
+			break
+		}
+	}
+	return step
+}
+
+// prev returns the index of the last step before the given one which is not
+// skipped, or -1 if there is none.
+func (w *Wizard) prev(data interface{}, step int) int {
+	for step--; step >= 0; step-- {
+		if skip := w.Steps[step].Skip; skip == nil || !skip(data) {
+			break
+		}
+	}
+	return step
+}
+
+// form returns a new form for the given step.
+func (w *Wizard) form(data interface{}, step int) (*Form, error) {
+	form, err := w.Steps[step].Spec.TryNew(data)
+	if err != nil {
+		return nil, err
+	}
+	form.reserved = []string{WizardStepParam, WizardBackParam,
+		WizardStateParam}
+	return form, nil
+}
+
+// page saves the state and returns the page for the given step. form is the
+// form of the step if it has already been created. saved is the encoded
+// data to be saved instead of data if not nil.
+func (w *Wizard) page(data interface{}, step int, form *Form,
+	saved json.RawMessage) (*WizardPage, error) {
+	if step >= len(w.Steps) {
+		return &WizardPage{Step: step, Done: true}, nil
+	}
+	if form == nil {
+		var err error
+		if form, err = w.form(data, step); err != nil {
+			return nil, err
+		}
+	}
+	if saved == nil {
+		var err error
+		if saved, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("form: Could not encode wizard data: %v",
+				err)
+		}
+	}
+	state, err := json.Marshal(wizardState{Step: step, Data: saved})
+	if err != nil {
+		return nil, fmt.Errorf("form: Could not encode wizard state: %v", err)
+	}
+	hidden, err := w.Store.Save(state)
+	if err != nil {
+		return nil, err
+	}
+	form.hidden = template.HTML(fmt.Sprintf(
+		`<input type="hidden" name="%v" value="%v"/>`, WizardStepParam,
+		step)) + hidden
+	return &WizardPage{Step: step, Form: form, Back: w.prev(data, step) >= 0},
+		nil
+}
@@ -0,0 +1,251 @@
+// This file is part of monsti/form.
+// Copyright 2012-2014 Christian Neumann
+
+// monsti/form is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Lesser General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option) any
+// later version.
+
+// monsti/form is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS
+// FOR A PARTICULAR PURPOSE. See the GNU Lesser General Public License for more
+// details.
+
+// You should have received a copy of the GNU Lesser General Public License
+// along with monsti/form. If not, see <http://www.gnu.org/licenses/>.
+
+package form
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestWizardData struct {
+	Name     string
+	Business bool
+	Company  string
+	Email    string
+}
+
+func testWizard(store WizardStore) *Wizard {
+	return &Wizard{
+		Steps: []WizardStep{
+			{Spec: NewFormSpec(Form{Fields: []Field{
+				Field{Id: "Name", Label: "Name",
+					Validator: Required("Required.")},
+				Field{Id: "Business", Label: "Business"}}})},
+			{Spec: NewFormSpec(Form{Fields: []Field{
+				Field{Id: "Company", Label: "Company",
+					Validator: Required("Required.")}}}),
+				Skip: func(data interface{}) bool {
+					return !data.(*TestWizardData).Business
+				}},
+			{Spec: NewFormSpec(Form{Fields: []Field{
+				Field{Id: "Email", Label: "Email",
+					Validator: Required("Required.")}}})},
+		},
+		Store: store,
+	}
+}
+
+var wizardStateRegexp = regexp.MustCompile(`name="wizard.state" value="([^"]*)"`)
+
+// submitWizard submits the given values for the given page, adding the
+// wizard parameters.
+func submitWizard(t *testing.T, wizard *Wizard, page *WizardPage,
+	values url.Values) *WizardPage {
+	values.Set(WizardStepParam, strings.Split(
+		strings.Split(string(page.Form.RenderData().Hidden), `value="`)[1],
+		`"`)[0])
+	if match := wizardStateRegexp.FindStringSubmatch(
+		string(page.Form.RenderData().Hidden)); match != nil {
+		values.Set(WizardStateParam, match[1])
+	}
+	next, err := wizard.Process(new(TestWizardData), values)
+	if err != nil {
+		t.Fatalf("Process(%v) failed: %v", values, err)
+	}
+	return next
+}
+
+func TestWizard(t *testing.T) {
+	wizard := testWizard(SignedStore{Key: []byte("secret")})
+	data := new(TestWizardData)
+	page, err := wizard.Process(data, nil)
+	if err != nil || page.Step != 0 || page.Back || page.Done {
+		t.Fatalf("Process(nil) = %+v, %v", page, err)
+	}
+
+	// Invalid step, presented again.
+	page = submitWizard(t, wizard, page, url.Values{"Name": {""}})
+	if page.Step != 0 || len(page.Form.RenderData().Fields[0].Errors) != 1 {
+		t.Fatalf("Invalid step should be presented again, got %+v", page)
+	}
+
+	// Business step gets skipped.
+	page = submitWizard(t, wizard, page, url.Values{"Name": {"Foo"}})
+	if page.Step != 2 || !page.Back {
+		t.Fatalf("Should skip to step 2, got %+v", page)
+	}
+
+	// Back to the first step, then to the business step.
+	page = submitWizard(t, wizard, page, url.Values{WizardBackParam: {"1"}})
+	if page.Step != 0 || page.Back {
+		t.Fatalf("Should go back to step 0, got %+v", page)
+	}
+	page = submitWizard(t, wizard, page,
+		url.Values{"Name": {"Foo"}, "Business": {"true"}})
+	if page.Step != 1 {
+		t.Fatalf("Should go to step 1, got %+v", page)
+	}
+	page = submitWizard(t, wizard, page, url.Values{"Company": {"Bar"}})
+	if page.Step != 2 {
+		t.Fatalf("Should go to step 2, got %+v", page)
+	}
+
+	// Stale submission of step 1.
+	stale := url.Values{"Company": {"Baz"}}
+	stale.Set(WizardStateParam, wizardStateRegexp.FindStringSubmatch(
+		string(page.Form.RenderData().Hidden))[1])
+	stale.Set(WizardStepParam, "1")
+	if stalePage, err := wizard.Process(new(TestWizardData), stale); err != nil ||
+		stalePage.Step != 2 {
+		t.Errorf("Stale submission should present step 2, got %+v, %v",
+			stalePage, err)
+	}
+
+	values := url.Values{"Email": {"foo@example.com"}}
+	values.Set(WizardStepParam, "2")
+	values.Set(WizardStateParam, wizardStateRegexp.FindStringSubmatch(
+		string(page.Form.RenderData().Hidden))[1])
+	data = new(TestWizardData)
+	page, err = wizard.Process(data, values)
+	if err != nil || !page.Done {
+		t.Fatalf("Wizard should be done, got %+v, %v", page, err)
+	}
+	expected := TestWizardData{"Foo", true, "Bar", "foo@example.com"}
+	if *data != expected {
+		t.Errorf("Wizard data = %+v, should be %+v", *data, expected)
+	}
+
+	// Tampered state.
+	values.Set(WizardStateParam, "x"+values.Get(WizardStateParam))
+	if _, err := wizard.Process(new(TestWizardData), values); err == nil {
+		t.Errorf("Process should fail for tampered state")
+	}
+}
+
+func TestWizardStrict(t *testing.T) {
+	wizard := testWizard(SignedStore{Key: []byte("secret")})
+	wizard.Steps[0].Spec = NewFormSpec(Form{
+		Fields: []Field{Field{Id: "Name", Label: "Name"}},
+		Strict: &StrictMode{}})
+	page, err := wizard.Process(new(TestWizardData), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page = submitWizard(t, wizard, page, url.Values{"Name": {"Foo"}})
+	if page.Step != 2 {
+		t.Errorf("Wizard parameters should be allowed in strict mode, got %+v",
+			page.Form.RenderData().Errors)
+	}
+}
+
+func TestSignedStoreMaxAge(t *testing.T) {
+	defer func() { now = time.Now }()
+	store := SignedStore{Key: []byte("secret"), MaxAge: time.Hour}
+	now = func() time.Time { return time.Unix(1000000, 0) }
+	hidden, err := store.Save([]byte("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{WizardStateParam: {
+		wizardStateRegexp.FindStringSubmatch(string(hidden))[1]}}
+	now = func() time.Time { return time.Unix(1000000, 0).Add(time.Minute) }
+	if state, err := store.Load(values); err != nil || string(state) != "state" {
+		t.Errorf("Load() = %q, %v", state, err)
+	}
+	now = func() time.Time { return time.Unix(1000000, 0).Add(2 * time.Hour) }
+	if _, err := store.Load(values); err == nil {
+		t.Errorf("Load should fail for expired state")
+	}
+}
+
+func TestSignedStoreName(t *testing.T) {
+	signup := SignedStore{Key: []byte("secret"), Name: "signup"}
+	hidden, err := signup.Save([]byte("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{WizardStateParam: {
+		wizardStateRegexp.FindStringSubmatch(string(hidden))[1]}}
+	if state, err := signup.Load(values); err != nil ||
+		string(state) != "state" {
+		t.Errorf("Load() = %q, %v", state, err)
+	}
+	checkout := SignedStore{Key: []byte("secret"), Name: "checkout"}
+	if _, err := checkout.Load(values); err == nil {
+		t.Errorf("Load should fail for the state of another wizard")
+	}
+}
+
+func TestSignedStoreKey(t *testing.T) {
+	store := SignedStore{Name: "signup"}
+	if _, err := store.Save([]byte("state")); err == nil {
+		t.Errorf("Save should fail without a key")
+	}
+	values := url.Values{WizardStateParam: {sign(nil, store.purpose(),
+		[]byte("12345678state"))}}
+	if _, err := store.Load(values); err == nil {
+		t.Errorf("Load should fail without a key")
+	}
+}
+
+type testSession map[string][]byte
+
+func (s testSession) Get(key string) []byte        { return s[key] }
+func (s testSession) Set(key string, value []byte) { s[key] = value }
+
+func TestSessionStore(t *testing.T) {
+	session := make(testSession)
+	wizard := testWizard(SessionStore{Session: session})
+	page, err := wizard.Process(new(TestWizardData), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session["wizard"] == nil {
+		t.Fatalf("State should be stored in session")
+	}
+	if strings.Contains(string(page.Form.RenderData().Hidden), WizardStateParam) {
+		t.Errorf("State should not be rendered")
+	}
+	page = submitWizard(t, wizard, page,
+		url.Values{"Name": {"Foo"}, "Business": {"1"}})
+	if page.Step != 1 {
+		t.Errorf("Should go to step 1, got %+v", page)
+	}
+
+	// Invalid submissions don't change the saved data.
+	page = submitWizard(t, wizard, page, url.Values{WizardBackParam: {"1"}})
+	page = submitWizard(t, wizard, page, url.Values{"Name": {""}})
+	if page.Step != 0 || len(page.Form.RenderData().Fields[0].Errors) != 1 {
+		t.Fatalf("Invalid step should be presented again, got %+v", page)
+	}
+	var state wizardState
+	data := new(TestWizardData)
+	if err := json.Unmarshal(session["wizard"], &state); err != nil ||
+		json.Unmarshal(state.Data, data) != nil || data.Name != "Foo" {
+		t.Errorf("Saved state should contain validated data, got %s",
+			session["wizard"])
+	}
+	delete(session, "wizard")
+	values := url.Values{"Company": {"Bar"}, WizardStepParam: {"1"}}
+	if _, err := wizard.Process(new(TestWizardData), values); err == nil {
+		t.Errorf("Process should fail without state")
+	}
+}